@@ -1,43 +1,154 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// configFileEnv names the environment variable with the path of the config
+// file, used when the -config flag is not given.
+const configFileEnv = "CONFIG_FILE"
+
 type Config struct {
 	DBHost     string
-	DBPort     string
+	DBPort     int
 	DBUser     string
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
-	ServerPort string
+	ServerPort int
 	LogLevel   string
+	LogFormat  string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	DBTimeout       time.Duration
+	ImportTimeout   time.Duration
+
+	StrictDuplicates bool
+	BaseCurrency     string
+	IdempotencyTTL   time.Duration
+	TrashRetention   time.Duration
+	PurgeInterval    time.Duration
+
+	// origins tells where every setting was taken from, by key.
+	origins map[string]string
 }
 
-func Load() *Config {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
-	}
+// ErrUsage is returned by Load for malformed command-line arguments, after
+// the problem and the usage have been written to its output.
+var ErrUsage = errors.New("invalid command-line arguments")
+
+// ValidationError lists every problem found in the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
 
+func defaults() *Config {
 	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBName:     getEnv("DB_NAME", "subscriptions"),
-		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		DBHost:     "localhost",
+		DBPort:     5432,
+		DBUser:     "postgres",
+		DBPassword: "password",
+		DBName:     "subscriptions",
+		DBSSLMode:  "disable",
+		ServerPort: 8080,
+		LogLevel:   "info",
+		LogFormat:  "text",
+
+		ReadTimeout:     30 * time.Second,
+		WriteTimeout:    60 * time.Second,
+		IdleTimeout:     120 * time.Second,
+		ShutdownTimeout: 25 * time.Second,
+		DBTimeout:       10 * time.Second,
+		ImportTimeout:   time.Minute,
+
+		StrictDuplicates: false,
+		BaseCurrency:     "RUB",
+		IdempotencyTTL:   24 * time.Hour,
+		TrashRetention:   30 * 24 * time.Hour,
+		PurgeInterval:    time.Hour,
 	}
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the config file, the environment (including a .env file) and the
+// command-line flags in args. The config file is given by the -config flag or
+// CONFIG_FILE. Secrets can also be read from the file named by their key with
+// a _FILE suffix, such as DB_PASSWORD_FILE. Every malformed or invalid value
+// is reported at once in a *ValidationError. Usage goes to output, and -h
+// returns flag.ErrHelp.
+func Load(name string, args []string, output io.Writer) (*Config, error) {
+	cfg := defaults()
+	cfg.origins = make(map[string]string)
+	settings := cfg.settings()
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	configFile := flags.String("config", "", "YAML or TOML config `file` (env "+configFileEnv+")")
+	for _, s := range settings {
+		if s.secret {
+			key := s.key + fileSuffix
+			flags.Var(&rawFlag{}, flagName(key), fmt.Sprintf("`file` to read the %s from (env %s)", s.usage, envName(key)))
+			continue
+		}
+		usage := fmt.Sprintf("%s (`%s`, env %s)", s.usage, s.value.kind(), envName(s.key))
+		flags.Var(&rawFlag{boolean: s.value.kind() == "bool"}, flagName(s.key), usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, ErrUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(output, "unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return nil, ErrUsage
+	}
+
+	var problems []string
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		problems = append(problems, fmt.Sprintf(".env: %s", err))
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+	if path != "" {
+		values, fileProblems := readFile(path)
+		problems = append(problems, fileProblems...)
+		problems = append(problems, cfg.apply(settings, fileSource(path, values))...)
+	}
+
+	problems = append(problems, cfg.apply(settings, envSource())...)
+
+	set := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			set[strings.ReplaceAll(f.Name, "-", "_")] = f.Value.String()
+		}
+	})
+	problems = append(problems, cfg.apply(settings, flagSource(set))...)
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
-	return defaultValue
+	return cfg, nil
 }
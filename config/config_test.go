@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable Load reads, for the duration of the test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv(configFileEnv, "")
+	for _, s := range defaults().settings() {
+		t.Setenv(envName(s.key), "")
+		if s.secret {
+			t.Setenv(envName(s.key+fileSuffix), "")
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load("test", args, io.Discard)
+}
+
+func TestFileKeepsScalarText(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", "db_password: 0123\ndb_name: 1e3\ndb_user: 0x1F\ndb_host: 2024-01-01\ndb_port: 6543\nstrict_duplicates: true\n")
+
+	cfg, err := load(t, "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ name, got, want string }{
+		{"db_password", cfg.DBPassword, "0123"},
+		{"db_name", cfg.DBName, "1e3"},
+		{"db_user", cfg.DBUser, "0x1F"},
+		{"db_host", cfg.DBHost, "2024-01-01"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if cfg.DBPort != 6543 || !cfg.StrictDuplicates {
+		t.Errorf("db_port = %d, strict_duplicates = %v, want 6543 and true", cfg.DBPort, cfg.StrictDuplicates)
+	}
+}
+
+func TestFileRejectsMismatchedValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		problem string
+	}{
+		{"yaml list", "config.yaml", "db_host: [a, b]\n", "config.yaml db_host: must be a string, number or boolean"},
+		{"yaml null", "config.yaml", "db_host:\n", "config.yaml db_host: must be a string, number or boolean"},
+		{"yaml hex port", "config.yaml", "db_port: 0x1F\n", `config.yaml db_port: must be an integer, got "0x1F"`},
+		{"toml number for string", "config.toml", "db_password = 123\n", "config.toml db_password: must be a string"},
+		{"toml float for int", "config.toml", "db_port = 1e3\n", "config.toml db_port: must be an integer"},
+		{"toml string for bool", "config.toml", "strict_duplicates = \"yes\"\n", "config.toml strict_duplicates: must be true or false"},
+		{"unknown key", "config.toml", "db_hots = \"db\"\n", "config.toml: unknown keys db_hots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := load(t, "-config", writeFile(t, tt.file, tt.content))
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			for _, problem := range invalid.Problems {
+				if strings.HasSuffix(problem, tt.problem) {
+					return
+				}
+			}
+			t.Errorf("problems = %q, want one ending in %q", invalid.Problems, tt.problem)
+		})
+	}
+}
+
+func TestTOMLValues(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.toml", "db_password = \"0123\"\ndb_port = 0x1F\nstrict_duplicates = true\nhttp_read_timeout = \"45s\"\n")
+
+	cfg, err := load(t, "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBPassword != "0123" || cfg.DBPort != 31 || !cfg.StrictDuplicates || cfg.ReadTimeout.String() != "45s" {
+		t.Errorf("got db_password %q, db_port %d, strict_duplicates %v, http_read_timeout %s",
+			cfg.DBPassword, cfg.DBPort, cfg.StrictDuplicates, cfg.ReadTimeout)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   bool
+		env    bool
+		flag   bool
+		want   string
+		origin string
+	}{
+		{"default", false, false, false, "localhost", "default"},
+		{"file over default", true, false, false, "file-host", " db_host"},
+		{"env over file", true, true, false, "env-host", "environment DB_HOST"},
+		{"flag over env", true, true, true, "flag-host", "flag -db-host"},
+		{"flag over file", true, false, true, "flag-host", "flag -db-host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			var args []string
+			if tt.file {
+				args = append(args, "-config", writeFile(t, "config.yaml", "db_host: file-host\n"))
+			}
+			if tt.env {
+				t.Setenv("DB_HOST", "env-host")
+			}
+			if tt.flag {
+				args = append(args, "-db-host", "flag-host")
+			}
+
+			cfg, err := load(t, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DBHost != tt.want {
+				t.Errorf("db_host = %q, want %q", cfg.DBHost, tt.want)
+			}
+			if origin := cfg.Origin("db_host"); !strings.HasSuffix(origin, tt.origin) {
+				t.Errorf("origin = %q, want one ending in %q", origin, tt.origin)
+			}
+		})
+	}
+}
+
+func TestSecretFromFile(t *testing.T) {
+	secret := writeFile(t, "password", "s3cret\n")
+
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		want    string
+		problem string
+	}{
+		{name: "env file", env: map[string]string{"DB_PASSWORD_FILE": secret}, want: "s3cret"},
+		{name: "config file", config: "db_password_file: " + secret + "\n", want: "s3cret"},
+		{name: "env file over config value", config: "db_password: file\n", env: map[string]string{"DB_PASSWORD_FILE": secret}, want: "s3cret"},
+		{name: "env value over config file", config: "db_password_file: " + secret + "\n", env: map[string]string{"DB_PASSWORD": "env"}, want: "env"},
+		{name: "both in env", env: map[string]string{"DB_PASSWORD": "env", "DB_PASSWORD_FILE": secret},
+			problem: "environment DB_PASSWORD: set only one of environment DB_PASSWORD and environment DB_PASSWORD_FILE"},
+		{name: "missing file", env: map[string]string{"DB_PASSWORD_FILE": secret + ".missing"}, problem: "environment DB_PASSWORD_FILE: open "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var args []string
+			if tt.config != "" {
+				args = append(args, "-config", writeFile(t, "config.yaml", tt.config))
+			}
+
+			cfg, err := load(t, args...)
+			if tt.problem != "" {
+				var invalid *ValidationError
+				if !errors.As(err, &invalid) || len(invalid.Problems) != 1 || !strings.HasPrefix(invalid.Problems[0], tt.problem) {
+					t.Fatalf("err = %v, want the problem %q", err, tt.problem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DBPassword != tt.want {
+				t.Errorf("db_password = %q, want %q", cfg.DBPassword, tt.want)
+			}
+		})
+	}
+}
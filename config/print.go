@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"io"
+)
+
+const redacted = `"[REDACTED]"`
+
+// Origin tells where the setting with key was taken from, such as
+// "environment DB_PORT", or "default".
+func (c *Config) Origin(key string) string {
+	if origin, ok := c.origins[key]; ok {
+		return origin
+	}
+	return "default"
+}
+
+// Print writes the effective configuration as YAML that can be used as a
+// config file, with secrets redacted and where every value came from as a
+// comment.
+func (c *Config) Print(w io.Writer) error {
+	for _, s := range c.settings() {
+		value := s.value.String()
+		if s.secret {
+			value = redacted
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", s.key, value, c.Origin(s.key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
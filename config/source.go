@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fileSuffix marks the key of a secret whose value is read from a file.
+const fileSuffix = "_file"
+
+// setting is one configuration value. Its key names it in the config file, in
+// upper case it is its environment variable and with dashes its flag.
+type setting struct {
+	key   string
+	usage string
+	// secret values are redacted when printed and have no flag, so they do
+	// not show in the process list; they can be read from a file instead.
+	secret bool
+	value  value
+}
+
+// value parses a setting into a field of Config and formats it back.
+type value interface {
+	set(s string) error
+	String() string
+	kind() string
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{key: "db_host", usage: "PostgreSQL host", value: (*stringValue)(&c.DBHost)},
+		{key: "db_port", usage: "PostgreSQL port", value: (*intValue)(&c.DBPort)},
+		{key: "db_user", usage: "database user", value: (*stringValue)(&c.DBUser)},
+		{key: "db_password", usage: "database password", secret: true, value: (*stringValue)(&c.DBPassword)},
+		{key: "db_name", usage: "database name", value: (*stringValue)(&c.DBName)},
+		{key: "db_sslmode", usage: "PostgreSQL SSL mode", value: (*stringValue)(&c.DBSSLMode)},
+		{key: "server_port", usage: "HTTP port", value: (*intValue)(&c.ServerPort)},
+		{key: "log_level", usage: "log level", value: (*stringValue)(&c.LogLevel)},
+		{key: "log_format", usage: "log format, text or json", value: (*stringValue)(&c.LogFormat)},
+		{key: "http_read_timeout", usage: "time to read a request", value: (*durationValue)(&c.ReadTimeout)},
+		{key: "http_write_timeout", usage: "time to handle a request and write the response", value: (*durationValue)(&c.WriteTimeout)},
+		{key: "http_idle_timeout", usage: "time to wait for the next keep-alive request", value: (*durationValue)(&c.IdleTimeout)},
+		{key: "shutdown_timeout", usage: "time to finish running requests on shutdown", value: (*durationValue)(&c.ShutdownTimeout)},
+		{key: "db_timeout", usage: "time a request may spend on the database, 0 for no limit", value: (*durationValue)(&c.DBTimeout)},
+		{key: "import_timeout", usage: "db_timeout of imports", value: (*durationValue)(&c.ImportTimeout)},
+		{key: "strict_duplicates", usage: "reject overlapping subscriptions", value: (*boolValue)(&c.StrictDuplicates)},
+		{key: "base_currency", usage: "currency of exchange rates and budgets", value: (*stringValue)(&c.BaseCurrency)},
+		{key: "idempotency_ttl", usage: "time Idempotency-Key responses are kept", value: (*durationValue)(&c.IdempotencyTTL)},
+		{key: "trash_retention", usage: "time deleted subscriptions are kept, 0 to keep them", value: (*durationValue)(&c.TrashRetention)},
+		{key: "purge_interval", usage: "interval of trash purges", value: (*durationValue)(&c.PurgeInterval)},
+	}
+}
+
+// source looks up settings by key. origin names the setting as the source
+// knows it, for messages.
+type source struct {
+	lookup func(key string) (string, bool, error)
+	origin func(key string) string
+}
+
+// apply sets every setting found in src and returns the problems found.
+func (c *Config) apply(settings []setting, src source) []string {
+	var problems []string
+	for _, s := range settings {
+		raw, ok, err := src.lookup(s.key)
+		origin := src.origin(s.key)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", origin, err))
+			continue
+		}
+		if s.secret {
+			path, fromFile, err := src.lookup(s.key + fileSuffix)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", src.origin(s.key+fileSuffix), err))
+				continue
+			}
+			if fromFile {
+				if ok {
+					problems = append(problems, fmt.Sprintf("%s: set only one of %s and %s", origin, origin, src.origin(s.key+fileSuffix)))
+					continue
+				}
+				origin = src.origin(s.key + fileSuffix)
+				content, err := os.ReadFile(path)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s", origin, err))
+					continue
+				}
+				raw, ok = strings.TrimRight(string(content), "\r\n"), true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := s.value.set(raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", origin, err))
+			continue
+		}
+		c.origins[s.key] = origin
+	}
+	return problems
+}
+
+func envSource() source {
+	return source{
+		lookup: func(key string) (string, bool, error) {
+			value, ok := os.LookupEnv(envName(key))
+			return value, ok && value != "", nil
+		},
+		origin: func(key string) string { return "environment " + envName(key) },
+	}
+}
+
+func flagSource(values map[string]string) source {
+	return source{
+		lookup: func(key string) (string, bool, error) {
+			value, ok := values[key]
+			return value, ok, nil
+		},
+		origin: func(key string) string { return "flag -" + flagName(key) },
+	}
+}
+
+// fileSource looks settings up in the values of a config file, kept as
+// written by readFile.
+func fileSource(path string, values map[string]string) source {
+	return source{
+		lookup: func(key string) (string, bool, error) {
+			value, ok := values[key]
+			return value, ok, nil
+		},
+		origin: func(key string) string { return path + " " + key },
+	}
+}
+
+// readFile reads the settings of a YAML or TOML config file, by its
+// extension, and returns them with the problems found, such as unknown keys.
+// Values keep the text they are written with, so that 0123 stays 0123 rather
+// than becoming the number 83.
+func readFile(path string) (map[string]string, []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	kinds := make(map[string]string)
+	for _, s := range defaults().settings() {
+		kinds[s.key] = s.value.kind()
+		if s.secret {
+			kinds[s.key+fileSuffix] = "string"
+		}
+	}
+
+	var values map[string]string
+	var problems []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, problems, err = readYAML(path, data)
+	case ".toml":
+		values, problems, err = readTOML(path, data, kinds)
+	default:
+		return nil, []string{fmt.Sprintf("%s: config file must be .yaml, .yml or .toml", path)}
+	}
+	if err != nil {
+		return nil, []string{fmt.Sprintf("%s: %s", path, err)}
+	}
+
+	var unknown []string
+	for key := range values {
+		if _, ok := kinds[key]; !ok {
+			unknown = append(unknown, key)
+			delete(values, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		problems = append(problems, fmt.Sprintf("%s: unknown keys %s", path, strings.Join(unknown, ", ")))
+	}
+	return values, problems
+}
+
+// readYAML returns the text of the scalars of a YAML mapping.
+func readYAML(path string, data []byte) (map[string]string, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	values := make(map[string]string)
+	if len(doc.Content) == 0 {
+		return values, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("must be a mapping of settings")
+	}
+
+	var problems []string
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if _, ok := values[key]; ok {
+			problems = append(problems, fmt.Sprintf("%s %s: set more than once", path, key))
+			continue
+		}
+		if value.Kind != yaml.ScalarNode || value.Tag == "!!null" {
+			problems = append(problems, fmt.Sprintf("%s %s: must be a string, number or boolean", path, key))
+			continue
+		}
+		values[key] = value.Value
+	}
+	return values, problems, nil
+}
+
+// tomlTypes describes the TOML value each kind of setting takes. TOML values
+// are typed, so a setting given a value of another type is reported rather
+// than converted.
+var tomlTypes = map[string]string{
+	"string":   "a string",
+	"duration": `a string such as "30s"`,
+	"int":      "an integer",
+	"bool":     "true or false",
+}
+
+// readTOML returns the values of a TOML document without tables, formatted
+// as the settings parse them.
+func readTOML(path string, data []byte, kinds map[string]string) (map[string]string, []string, error) {
+	var decoded map[string]interface{}
+	if err := toml.Unmarshal(data, &decoded); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string, len(decoded))
+	var problems []string
+	for key, value := range decoded {
+		kind, known := kinds[key]
+		text, ok := "", false
+		switch v := value.(type) {
+		case string:
+			text, ok = v, kind == "string" || kind == "duration"
+		case int64:
+			text, ok = strconv.FormatInt(v, 10), kind == "int"
+		case bool:
+			text, ok = strconv.FormatBool(v), kind == "bool"
+		}
+		if known && !ok {
+			problems = append(problems, fmt.Sprintf("%s %s: must be %s", path, key, tomlTypes[kind]))
+			continue
+		}
+		values[key] = text
+	}
+	return values, problems, nil
+}
+
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+type stringValue string
+
+func (v *stringValue) set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return strconv.Quote(string(*v)) }
+
+func (v *stringValue) kind() string { return "string" }
+
+type intValue int
+
+func (v *intValue) set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be an integer, got %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) kind() string { return "int" }
+
+type boolValue bool
+
+func (v *boolValue) set(s string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be true or false, got %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) kind() string { return "bool" }
+
+type durationValue time.Duration
+
+func (v *durationValue) set(s string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be a duration such as 30s or 1h, got %q", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return strconv.Quote(time.Duration(*v).String()) }
+
+func (v *durationValue) kind() string { return "duration" }
+
+// rawFlag keeps the text of a flag, which is parsed with the other sources.
+// A bool flag given without a value is true.
+type rawFlag struct {
+	text    string
+	boolean bool
+}
+
+func (f *rawFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.text
+}
+
+func (f *rawFlag) Set(s string) error {
+	f.text = s
+	return nil
+}
+
+func (f *rawFlag) IsBoolFlag() bool { return f.boolean }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true, "require": true, "verify-ca": true, "verify-full": true,
+}
+
+// validate checks the values that parsed but are out of range, naming each
+// by where it was set.
+func (c *Config) validate() []string {
+	var problems []string
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if ok {
+			return
+		}
+		name := key
+		if origin, set := c.origins[key]; set {
+			name = origin
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	check(c.DBHost != "", "db_host", "must not be empty")
+	check(c.DBPort > 0 && c.DBPort <= 65535, "db_port", "must be between 1 and 65535, got %d", c.DBPort)
+	check(c.DBUser != "", "db_user", "must not be empty")
+	check(c.DBName != "", "db_name", "must not be empty")
+	check(sslModes[c.DBSSLMode], "db_sslmode", "must be one of disable, allow, prefer, require, verify-ca or verify-full, got %q", c.DBSSLMode)
+	check(c.ServerPort > 0 && c.ServerPort <= 65535, "server_port", "must be between 1 and 65535, got %d", c.ServerPort)
+
+	_, err := logrus.ParseLevel(c.LogLevel)
+	check(err == nil, "log_level", "must be one of panic, fatal, error, warn, info, debug or trace, got %q", c.LogLevel)
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format", "must be text or json, got %q", c.LogFormat)
+
+	nonNegative := func(d time.Duration, key string) {
+		check(d >= 0, key, "must not be negative, got %s", d)
+	}
+	positive := func(d time.Duration, key string) {
+		check(d > 0, key, "must be positive, got %s", d)
+	}
+	nonNegative(c.ReadTimeout, "http_read_timeout")
+	nonNegative(c.WriteTimeout, "http_write_timeout")
+	nonNegative(c.IdleTimeout, "http_idle_timeout")
+	positive(c.ShutdownTimeout, "shutdown_timeout")
+	nonNegative(c.DBTimeout, "db_timeout")
+	nonNegative(c.ImportTimeout, "import_timeout")
+	positive(c.IdempotencyTTL, "idempotency_ttl")
+	nonNegative(c.TrashRetention, "trash_retention")
+	if c.TrashRetention > 0 {
+		positive(c.PurgeInterval, "purge_interval")
+	}
+
+	check(isCurrencyCode(c.BaseCurrency), "base_currency", "must be a 3-letter upper-case ISO 4217 code, got %q", c.BaseCurrency)
+	return problems
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
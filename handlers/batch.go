@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchCreateSubscriptions creates many subscriptions at once
+// @Summary Create subscriptions in bulk
+// @Description Create many subscriptions in one request. In atomic mode (default) either every subscription is created or none, in best_effort mode each one is created on its own. Every item is validated like a single create and gets its own status
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param batch body models.SubscriptionBatchCreate true "Subscriptions to create"
+// @Success 200 {object} models.SubscriptionBatchResponse
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.SubscriptionBatchResponse
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions:batch [post]
+func (h *Handler) BatchCreateSubscriptions(c *gin.Context) {
+	var req models.SubscriptionBatchCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	results, err := h.Service.BatchCreate(c.Request.Context(), req.Mode, req.Items)
+	h.batchResponse(c, req.Mode, results, err)
+}
+
+// BatchUpdateSubscriptions patches many subscriptions at once
+// @Summary Patch subscriptions in bulk
+// @Description Apply a JSON Merge Patch to many subscriptions in one request. In atomic mode (default) either every patch is applied or none, in best_effort mode each one is applied on its own. if_match of an item works like the If-Match header
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param batch body models.SubscriptionBatchUpdate true "Patches to apply"
+// @Success 200 {object} models.SubscriptionBatchResponse
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.SubscriptionBatchResponse
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions:batch [patch]
+func (h *Handler) BatchUpdateSubscriptions(c *gin.Context) {
+	var req models.SubscriptionBatchUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	results, err := h.Service.BatchUpdate(c.Request.Context(), req.Mode, req.Items)
+	h.batchResponse(c, req.Mode, results, err)
+}
+
+// BatchDeleteSubscriptions deletes many subscriptions at once
+// @Summary Delete subscriptions in bulk
+// @Description Delete many subscriptions in one request. In atomic mode (default) either every subscription is deleted or none, in best_effort mode each one is deleted on its own. if_match of an item works like the If-Match header
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param batch body models.SubscriptionBatchDelete true "Subscriptions to delete"
+// @Success 200 {object} models.SubscriptionBatchResponse
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.SubscriptionBatchResponse
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions:batch [delete]
+func (h *Handler) BatchDeleteSubscriptions(c *gin.Context) {
+	var req models.SubscriptionBatchDelete
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	results, err := h.Service.BatchDelete(c.Request.Context(), req.Mode, req.Items)
+	h.batchResponse(c, req.Mode, results, err)
+}
+
+// batchResponse reports the result of every item of a batch. An atomic batch
+// with a failed item gets 422. A failed item carries the status, code and
+// details of the problem its single item request would get.
+func (h *Handler) batchResponse(c *gin.Context, mode string, results []service.BatchResult, err error) {
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if mode == "" {
+		mode = models.BatchModeAtomic
+	}
+	response := models.SubscriptionBatchResponse{Mode: mode, Items: make([]models.SubscriptionBatchResult, len(results))}
+	for i, result := range results {
+		item := models.SubscriptionBatchResult{Index: i, Status: http.StatusOK, Subscription: result.Subscription, Warnings: result.Warnings}
+		if result.Err != nil {
+			problem := problemFor(result.Err)
+			item.Status = problem.Status
+			item.Code = problem.Code
+			item.Error = problem.Detail
+			item.Errors = problem.Errors
+			item.ConflictingID = problem.ConflictingID
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+		response.Items[i] = item
+	}
+
+	status := http.StatusOK
+	if mode == models.BatchModeAtomic && response.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, response)
+}
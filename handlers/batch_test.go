@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeBatchService answers BatchCreate with results.
+type fakeBatchService struct {
+	service.SubscriptionService
+	results []service.BatchResult
+	items   []models.SubscriptionCreate
+}
+
+func (f *fakeBatchService) BatchCreate(_ context.Context, _ string, items []models.SubscriptionCreate) ([]service.BatchResult, error) {
+	f.items = items
+	return f.results, nil
+}
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestBatchCreateRolledBack(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := &fakeBatchService{results: []service.BatchResult{
+		{Err: service.ErrBatchRolledBack},
+		{Err: &service.ValidationError{Fields: []models.FieldError{{Field: "price", Message: "is required"}}}},
+	}}
+	h := NewHandler(svc, testLogger())
+	router := gin.New()
+	router.POST("/subscriptions:batch", h.BatchCreateSubscriptions)
+
+	body := `{"items": [{"service_name": "A", "price": 100, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"},
+		{"service_name": "B"}]}`
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/subscriptions:batch", strings.NewReader(body)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body)
+	}
+	if len(svc.items) != 2 || svc.items[1].Price != nil {
+		t.Errorf("items = %+v, want both items passed on to be checked one by one", svc.items)
+	}
+	var response models.SubscriptionBatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Failed != 2 || response.Items[0].Status != http.StatusFailedDependency ||
+		response.Items[1].Status != http.StatusBadRequest || len(response.Items[1].Errors) != 1 {
+		t.Errorf("response = %+v, want item 0 with 424 and item 1 with 400", response)
+	}
+}
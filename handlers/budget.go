@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type BudgetHandler struct {
+	Service service.BudgetService
+	Logger  *logrus.Logger
+}
+
+func NewBudgetHandler(svc service.BudgetService, logger *logrus.Logger) *BudgetHandler {
+	return &BudgetHandler{
+		Service: svc,
+		Logger:  logger,
+	}
+}
+
+// CreateBudget creates a new budget
+// @Summary Create a new budget
+// @Description Create a monthly budget for a user, optionally scoped to one service
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param budget body models.BudgetCreate true "Budget data"
+// @Success 201 {object} models.Budget
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets [post]
+func (h *BudgetHandler) CreateBudget(c *gin.Context) {
+	var req models.BudgetCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	budget, err := h.Service.Create(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, budget)
+}
+
+// GetBudget gets a budget by ID
+// @Summary Get a budget by ID
+// @Description Get a budget by its ID
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Success 200 {object} models.Budget
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets/{id} [get]
+func (h *BudgetHandler) GetBudget(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	budget, err := h.Service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// ListBudgets lists budgets
+// @Summary List budgets
+// @Description List all budgets with optional filtering by user_id
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param user_id query string false "User ID"
+// @Success 200 {array} models.Budget
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets [get]
+func (h *BudgetHandler) ListBudgets(c *gin.Context) {
+	var userID *uuid.UUID
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		parsed, err := uuid.Parse(userIDStr)
+		if err != nil {
+			logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid user_id")
+			invalidParam(c, "user_id", "must be a UUID")
+			return
+		}
+		userID = &parsed
+	}
+
+	budgets, err := h.Service.List(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budgets)
+}
+
+// UpdateBudget updates a budget by ID
+// @Summary Update a budget
+// @Description Update a budget by its ID
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Param budget body models.BudgetUpdate true "Updated budget data"
+// @Success 200 {object} models.Budget
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets/{id} [put]
+func (h *BudgetHandler) UpdateBudget(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	var req models.BudgetUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	budget, err := h.Service.Update(c.Request.Context(), id, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// DeleteBudget deletes a budget by ID
+// @Summary Delete a budget
+// @Description Delete a budget by its ID
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets/{id} [delete]
+func (h *BudgetHandler) DeleteBudget(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.Delete(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetBudgetStatus reports planned spend against a budget
+// @Summary Get budget status
+// @Description Compare planned spend with the budget limit for every month of a period, by default the current month and the next eleven
+// @Tags budgets
+// @Accept json
+// @Produce json
+// @Param id path string true "Budget ID"
+// @Param start_period query string false "First month, MM-YYYY"
+// @Param end_period query string false "Last month, MM-YYYY"
+// @Success 200 {object} models.BudgetStatus
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /budgets/{id}/status [get]
+func (h *BudgetHandler) GetBudgetStatus(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.Service.Status(c.Request.Context(), id, c.Query("start_period"), c.Query("end_period"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *BudgetHandler) parseID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid budget ID")
+		invalidParam(c, "id", "must be a UUID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
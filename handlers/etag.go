@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag sets the ETag of a subscription response to its version.
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", `"`+strconv.Itoa(version)+`"`)
+}
+
+// ifMatchVersion reads the If-Match header. It returns nil for a missing
+// header or "*". ok is false if the header holds no ETag issued by setETag,
+// which can never match.
+func ifMatchVersion(c *gin.Context) (version *int, ok bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, false
+	}
+	parsed, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return nil, false
+	}
+	return &parsed, true
+}
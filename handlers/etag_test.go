@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// versionedSubscriptions is an in-memory SubscriptionRepository that checks
+// versions on Update and Delete as the SQL does. raceOnUpdate makes the next
+// Update find the row changed by someone else.
+type versionedSubscriptions struct {
+	repository.SubscriptionRepository
+	rows         map[uuid.UUID]models.Subscription
+	raceOnUpdate bool
+}
+
+func (r *versionedSubscriptions) GetByID(_ context.Context, id uuid.UUID) (*models.Subscription, error) {
+	sub, ok := r.rows[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &sub, nil
+}
+
+func (r *versionedSubscriptions) Update(_ context.Context, sub *models.Subscription) error {
+	stored := r.rows[sub.ID]
+	if r.raceOnUpdate {
+		r.raceOnUpdate = false
+		stored.Version++
+		r.rows[sub.ID] = stored
+	}
+	if stored.Version != sub.Version {
+		return repository.ErrVersionConflict
+	}
+	sub.Version++
+	r.rows[sub.ID] = *sub
+	return nil
+}
+
+func (r *versionedSubscriptions) Delete(_ context.Context, id uuid.UUID, version *int) error {
+	stored, ok := r.rows[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != nil && *version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	delete(r.rows, id)
+	return nil
+}
+
+type noBudgets struct {
+	repository.BudgetRepository
+}
+
+func (noBudgets) List(context.Context, models.BudgetFilter) ([]models.Budget, error) {
+	return nil, nil
+}
+
+// etagRouter serves a single subscription at version 1 through the real
+// service.
+func etagRouter(t *testing.T) (*gin.Engine, *versionedSubscriptions, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	id := uuid.New()
+	repo := &versionedSubscriptions{rows: map[uuid.UUID]models.Subscription{id: {
+		ID:            id,
+		ServiceName:   "Yandex Plus",
+		Price:         400,
+		Currency:      "RUB",
+		UserID:        uuid.New(),
+		StartDate:     "07-2025",
+		BillingPeriod: models.BillingPeriodMonth,
+		Version:       1,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		Prices:        []models.SubscriptionPrice{{EffectiveFrom: "07-2025", Price: 400}},
+	}}}
+	svc := service.NewSubscriptionService(repo, noBudgets{}, nil, testLogger(), service.SubscriptionOptions{BaseCurrency: "RUB"})
+	h := NewHandler(svc, testLogger())
+
+	router := gin.New()
+	router.GET("/subscriptions/:id", h.GetSubscription)
+	router.PUT("/subscriptions/:id", h.ReplaceSubscription)
+	router.PATCH("/subscriptions/:id", h.UpdateSubscription)
+	router.DELETE("/subscriptions/:id", h.DeleteSubscription)
+	return router, repo, "/subscriptions/" + id.String()
+}
+
+func send(router http.Handler, method, path, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+const replaceBody = `{"service_name": "Yandex Plus", "price": 500, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"}`
+
+func TestETagChangesAfterWrite(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+	}{
+		{http.MethodPut, replaceBody},
+		{http.MethodPatch, `{"price": 500}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			router, _, path := etagRouter(t)
+
+			etag := send(router, http.MethodGet, path, "", "").Header().Get("ETag")
+			if etag != `"1"` {
+				t.Fatalf("GET ETag = %q, want \"1\"", etag)
+			}
+			rec := send(router, tt.method, path, etag, tt.body)
+			if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+				t.Fatalf("%s = %d with ETag %q, want 200 with \"2\": %s", tt.method, rec.Code, rec.Header().Get("ETag"), rec.Body)
+			}
+			if etag := send(router, http.MethodGet, path, "", "").Header().Get("ETag"); etag != `"2"` {
+				t.Errorf("GET ETag after %s = %q, want \"2\"", tt.method, etag)
+			}
+		})
+	}
+}
+
+func TestStaleIfMatch(t *testing.T) {
+	tests := []struct {
+		method  string
+		ifMatch string
+		body    string
+	}{
+		{http.MethodPut, `"0"`, replaceBody},
+		{http.MethodPatch, `"0"`, `{"price": 500}`},
+		{http.MethodDelete, `"0"`, ""},
+		{http.MethodPatch, `W/"1"`, `{"price": 500}`}, // never issued
+		{http.MethodDelete, `1`, ""},                  // unquoted
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.ifMatch, func(t *testing.T) {
+			router, repo, path := etagRouter(t)
+
+			rec := send(router, tt.method, path, tt.ifMatch, tt.body)
+			if rec.Code != http.StatusPreconditionFailed {
+				t.Fatalf("status = %d, want 412: %s", rec.Code, rec.Body)
+			}
+			var problem models.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || problem.Code != models.ErrorCodePreconditionFailed {
+				t.Errorf("body = %s, want a %s problem", rec.Body, models.ErrorCodePreconditionFailed)
+			}
+			for _, sub := range repo.rows {
+				if sub.Version != 1 || sub.Price != 400 {
+					t.Errorf("subscription changed to version %d, price %d", sub.Version, sub.Price)
+				}
+			}
+		})
+	}
+}
+
+// If-Match is optional: without it a change applies to the current version,
+// unless the row changes between reading and writing it.
+func TestMissingIfMatch(t *testing.T) {
+	router, repo, path := etagRouter(t)
+
+	rec := send(router, http.MethodPatch, path, "", `{"price": 500}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("PATCH = %d with ETag %q, want 200 with \"2\"", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	repo.raceOnUpdate = true
+	rec = send(router, http.MethodPatch, path, "", `{"price": 600}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("PATCH racing another change = %d, want 409: %s", rec.Code, rec.Body)
+	}
+
+	rec = send(router, http.MethodDelete, path, "", "")
+	if rec.Code != http.StatusNoContent || len(repo.rows) != 0 {
+		t.Errorf("DELETE = %d, want 204", rec.Code)
+	}
+}
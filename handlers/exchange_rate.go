@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ExchangeRateHandler struct {
+	Service service.ExchangeRateService
+	Logger  *logrus.Logger
+}
+
+func NewExchangeRateHandler(svc service.ExchangeRateService, logger *logrus.Logger) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		Service: svc,
+		Logger:  logger,
+	}
+}
+
+// ImportExchangeRates imports monthly exchange rates from CSV
+// @Summary Import exchange rates
+// @Description Import currency,month,rate rows (rate is the value of one unit of currency in the base currency, month is MM-YYYY), replacing existing rates of the same currency and month
+// @Tags exchange-rates
+// @Accept text/csv
+// @Produce json
+// @Param rates body string true "CSV with currency,month,rate rows and an optional header row"
+// @Success 200 {object} models.ExchangeRateImportResponse
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /exchange-rates/import [post]
+func (h *ExchangeRateHandler) ImportExchangeRates(c *gin.Context) {
+	imported, err := h.Service.Import(c.Request.Context(), c.Request.Body)
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Failed to import exchange rates")
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ExchangeRateImportResponse{Imported: imported})
+}
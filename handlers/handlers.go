@@ -1,10 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
-	"errors"
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"em_subscription_test/internal/logging"
 	"em_subscription_test/internal/service"
 	"em_subscription_test/models"
 
@@ -31,26 +34,31 @@ func NewHandler(svc service.SubscriptionService, logger *logrus.Logger) *Handler
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Key to safely retry the request, the first response is replayed"
 // @Param subscription body models.SubscriptionCreate true "Subscription data"
-// @Success 201 {object} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Success 201 {object} models.SubscriptionWithWarnings
+// @Header 201 {string} ETag "Subscription version"
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions [post]
 func (h *Handler) CreateSubscription(c *gin.Context) {
 	var req models.SubscriptionCreate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.Logger.WithError(err).Error("Invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
 		return
 	}
 
-	subscription, err := h.Service.Create(&req)
+	subscription, warnings, err := h.Service.Create(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, subscription)
+	setETag(c, subscription.Version)
+	c.JSON(http.StatusCreated, models.SubscriptionWithWarnings{Subscription: *subscription, Warnings: warnings})
 }
 
 // GetSubscription gets a subscription by ID
@@ -61,45 +69,99 @@ func (h *Handler) CreateSubscription(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Subscription ID"
 // @Success 200 {object} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Subscription version, send it as If-Match to update or delete"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [get]
 func (h *Handler) GetSubscription(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		h.Logger.WithError(err).Error("Invalid subscription ID")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid subscription ID")
+		invalidParam(c, "id", "must be a UUID")
 		return
 	}
 
-	subscription, err := h.Service.GetByID(id)
+	subscription, err := h.Service.GetByID(c.Request.Context(), id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get subscription"})
+		respondError(c, err)
 		return
 	}
 
+	setETag(c, subscription.Version)
 	c.JSON(http.StatusOK, subscription)
 }
 
-// ListSubscriptions lists all subscriptions with optional filters
+// ListSubscriptions lists subscriptions page by page with optional filters
 // @Summary List subscriptions
-// @Description List all subscriptions with optional filtering by user_id and service_name
+// @Description List subscriptions matching all given filters, one page at a time. Pass next_cursor of a page as cursor to get the next one with the same sort
 // @Tags subscriptions
 // @Accept json
 // @Produce json
-// @Param user_id query string false "User ID"
-// @Param service_name query string false "Service Name"
-// @Success 200 {array} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Param user_id query []string false "User IDs, repeated or comma-separated" collectionFormat(multi)
+// @Param service_name query string false "Service name, exact match"
+// @Param service_name_prefix query string false "Service name prefix, case-insensitive"
+// @Param service_name_contains query string false "Service name substring, case-insensitive"
+// @Param active_at query string false "Active in this month, MM-YYYY"
+// @Param start_from query string false "Start date from, MM-YYYY inclusive"
+// @Param start_to query string false "Start date to, MM-YYYY inclusive"
+// @Param end_from query string false "End date from, MM-YYYY inclusive"
+// @Param end_to query string false "End date to, MM-YYYY inclusive"
+// @Param price_min query int false "Minimum current price, inclusive"
+// @Param price_max query int false "Maximum current price, inclusive"
+// @Param open_ended query bool false "Only subscriptions without end_date"
+// @Param limit query int false "Page size, 1-100" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(price, -price, start_date, -start_date, service_name, -service_name, created_at, -created_at) default(created_at)
+// @Success 200 {object} models.SubscriptionPage
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions [get]
 func (h *Handler) ListSubscriptions(c *gin.Context) {
+	h.listSubscriptions(c, h.Service.List)
+}
+
+// listSubscriptions responds with the page list returns for the filter and
+// page parameters of the request.
+func (h *Handler) listSubscriptions(c *gin.Context,
+	list func(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest) (*models.SubscriptionPage, error)) {
+	filter, err := parseSubscriptionFilter(c)
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid filter parameters")
+		respondError(c, err)
+		return
+	}
+
+	var page models.SubscriptionPageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid page parameters")
+		invalidBody(c, err)
+		return
+	}
+
+	subscriptions, err := list(c.Request.Context(), filter, &page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// ListDuplicates reports overlapping subscriptions of the same service
+// @Summary List duplicate subscriptions
+// @Description Find subscriptions of the same user and service whose periods overlap, with optional filtering by user_id and service_name
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param user_id query string false "User ID"
+// @Param service_name query string false "Service Name"
+// @Success 200 {array} models.DuplicateGroup
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/duplicates [get]
+func (h *Handler) ListDuplicates(c *gin.Context) {
 	userIDStr := c.Query("user_id")
 	serviceName := c.Query("service_name")
 
@@ -107,8 +169,8 @@ func (h *Handler) ListSubscriptions(c *gin.Context) {
 	if userIDStr != "" {
 		parsed, err := uuid.Parse(userIDStr)
 		if err != nil {
-			h.Logger.WithError(err).Error("Invalid user_id")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid user_id")
+			invalidParam(c, "user_id", "must be a UUID")
 			return
 		}
 		userID = &parsed
@@ -119,79 +181,146 @@ func (h *Handler) ListSubscriptions(c *gin.Context) {
 		svcName = &serviceName
 	}
 
-	subscriptions, err := h.Service.List(userID, svcName)
+	duplicates, err := h.Service.FindDuplicates(c.Request.Context(), userID, svcName)
 	if err != nil {
-		h.Logger.WithError(err).Error("Failed to list subscriptions")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list subscriptions"})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, subscriptions)
+	c.JSON(http.StatusOK, duplicates)
 }
 
-// UpdateSubscription updates a subscription by ID
-// @Summary Update a subscription
-// @Description Update a subscription by its ID
+// ReplaceSubscription replaces a subscription by ID
+// @Summary Replace a subscription
+// @Description Replace every field of a subscription by its ID. Omitted optional fields are reset to their defaults, the price history is kept when the price does not change
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription ID"
-// @Param subscription body models.SubscriptionUpdate true "Updated subscription data"
-// @Success 200 {object} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Param If-Match header string false "ETag of the subscription the change is based on"
+// @Param subscription body models.SubscriptionReplace true "Full subscription data"
+// @Success 200 {object} models.SubscriptionWithWarnings
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [put]
+func (h *Handler) ReplaceSubscription(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid subscription ID")
+		invalidParam(c, "id", "must be a UUID")
+		return
+	}
+
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
+		respondError(c, service.ErrPreconditionFailed)
+		return
+	}
+
+	var req models.SubscriptionReplace
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	subscription, warnings, err := h.Service.Replace(c.Request.Context(), id, &req, ifMatch)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, subscription.Version)
+	c.JSON(http.StatusOK, models.SubscriptionWithWarnings{Subscription: *subscription, Warnings: warnings})
+}
+
+// UpdateSubscription patches a subscription by ID
+// @Summary Patch a subscription
+// @Description Apply a JSON Merge Patch (RFC 7396) to a subscription by its ID. Absent fields are left unchanged, null clears end_date and billing_anchor
+// @Tags subscriptions
+// @Accept application/merge-patch+json
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Param If-Match header string false "ETag of the subscription the change is based on"
+// @Param subscription body models.SubscriptionUpdate true "Merge patch of the subscription"
+// @Success 200 {object} models.SubscriptionWithWarnings
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/{id} [patch]
 func (h *Handler) UpdateSubscription(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		h.Logger.WithError(err).Error("Invalid subscription ID")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid subscription ID")
+		invalidParam(c, "id", "must be a UUID")
+		return
+	}
+
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
+		respondError(c, service.ErrPreconditionFailed)
 		return
 	}
 
 	var req models.SubscriptionUpdate
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.Logger.WithError(err).Error("Invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
 		return
 	}
 
-	subscription, err := h.Service.Update(id, &req)
+	subscription, warnings, err := h.Service.Update(c.Request.Context(), id, &req, ifMatch)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, subscription)
+	setETag(c, subscription.Version)
+	c.JSON(http.StatusOK, models.SubscriptionWithWarnings{Subscription: *subscription, Warnings: warnings})
 }
 
-// DeleteSubscription deletes a subscription by ID
+// DeleteSubscription moves a subscription to the trash by ID
 // @Summary Delete a subscription
-// @Description Delete a subscription by its ID
+// @Description Move a subscription to the trash by its ID. It can be restored until it is purged after the retention period
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription ID"
+// @Param If-Match header string false "ETag of the subscription the deletion is based on"
 // @Success 204
-// @Failure 400 {object} map[string]string
-// @Failure 404 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 412 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [delete]
 func (h *Handler) DeleteSubscription(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		h.Logger.WithError(err).Error("Invalid subscription ID")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid subscription ID")
+		invalidParam(c, "id", "must be a UUID")
+		return
+	}
+
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
+		respondError(c, service.ErrPreconditionFailed)
 		return
 	}
 
-	err = h.Service.Delete(id)
+	err = h.Service.Delete(c.Request.Context(), id, ifMatch)
 	if err != nil {
-		h.Logger.WithError(err).Error("Failed to delete subscription")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		respondError(c, err)
 		return
 	}
 
@@ -200,28 +329,148 @@ func (h *Handler) DeleteSubscription(c *gin.Context) {
 
 // GetTotalCost calculates the total cost of subscriptions for a given period
 // @Summary Get total cost of subscriptions
-// @Description Calculate the total cost of subscriptions for a given period with optional filters
+// @Description Calculate the total cost of subscriptions for a given period with optional filters in target_currency, optionally broken down by group_by dimensions
 // @Tags subscriptions
 // @Accept json
 // @Produce json
+// @Param Idempotency-Key header string false "Key to safely retry the request, the first response is replayed"
 // @Param request body models.TotalCostRequest true "Total cost request"
 // @Success 200 {object} models.TotalCostResponse
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/total-cost [post]
 func (h *Handler) GetTotalCost(c *gin.Context) {
 	var req models.TotalCostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.Logger.WithError(err).Error("Invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	response, err := h.Service.GetTotalCost(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMonthlyCost returns the cost of subscriptions for each month of a period
+// @Summary Get monthly cost breakdown
+// @Description Calculate the cost of subscriptions for every month of a given period with optional filters
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body models.TotalCostRequest true "Total cost request"
+// @Success 200 {object} models.MonthlyCostResponse
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/total-cost/monthly [post]
+func (h *Handler) GetMonthlyCost(c *gin.Context) {
+	var req models.TotalCostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
+		return
+	}
+
+	response, err := h.Service.GetMonthlyCost(c.Request.Context(), &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetForecast projects subscription spend for the next months
+// @Summary Forecast subscription cost
+// @Description Project the cost of subscriptions for the next N months per month and per service, flagging subscriptions that end within the window
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body models.ForecastRequest true "Forecast request"
+// @Success 200 {object} models.ForecastResponse
+// @Failure 400 {object} models.Problem
+// @Failure 422 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/forecast [post]
+func (h *Handler) GetForecast(c *gin.Context) {
+	var req models.ForecastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid request body")
+		invalidBody(c, err)
 		return
 	}
 
-	response, err := h.Service.GetTotalCost(&req)
+	response, err := h.Service.Forecast(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// parseSubscriptionFilter reads the filter query parameters of a listing.
+// Empty parameters are ignored.
+func parseSubscriptionFilter(c *gin.Context) (*models.SubscriptionFilter, error) {
+	filter := &models.SubscriptionFilter{}
+
+	for _, value := range c.QueryArray("user_id") {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			userID, err := uuid.Parse(part)
+			if err != nil {
+				return nil, paramError("user_id", fmt.Sprintf("must be a UUID, got %q", part))
+			}
+			filter.UserIDs = append(filter.UserIDs, userID)
+		}
+	}
+
+	textParams := map[string]**string{
+		"service_name":          &filter.ServiceName,
+		"service_name_prefix":   &filter.ServiceNamePrefix,
+		"service_name_contains": &filter.ServiceNameContains,
+		"active_at":             &filter.ActiveAt,
+		"start_from":            &filter.StartFrom,
+		"start_to":              &filter.StartTo,
+		"end_from":              &filter.EndFrom,
+		"end_to":                &filter.EndTo,
+	}
+	for name, field := range textParams {
+		if value := c.Query(name); value != "" {
+			*field = &value
+		}
+	}
+
+	intParams := map[string]**int{
+		"price_min": &filter.PriceMin,
+		"price_max": &filter.PriceMax,
+	}
+	for name, field := range intParams {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, paramError(name, "must be an integer")
+			}
+			*field = &parsed
+		}
+	}
+
+	if value := c.Query("open_ended"); value != "" {
+		openEnded, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, paramError("open_ended", "must be a boolean")
+		}
+		filter.OpenEnded = openEnded
+	}
+
+	return filter, nil
+}
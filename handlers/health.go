@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// healthCheckTimeout bounds every readiness check.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck is a dependency the service needs to be ready. Check returns
+// details shown in the readiness report, and an error if the dependency is
+// not ready.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) (interface{}, error)
+}
+
+type HealthHandler struct {
+	Checks []HealthCheck
+	Logger *logrus.Logger
+}
+
+func NewHealthHandler(checks []HealthCheck, logger *logrus.Logger) *HealthHandler {
+	return &HealthHandler{
+		Checks: checks,
+		Logger: logger,
+	}
+}
+
+// Liveness reports that the process is up and serving requests. It checks
+// no dependencies, so a failing database does not get the service restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// Readiness runs every check and responds with 200 if all of them pass, else
+// with 503. Each check reports its latency.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	response := models.HealthResponse{Status: models.HealthStatusOK, Checks: make([]models.HealthCheckResult, 0, len(h.Checks))}
+	for _, check := range h.Checks {
+		result := h.run(c.Request.Context(), check)
+		if result.Status != models.HealthStatusOK {
+			response.Status = models.HealthStatusUnavailable
+		}
+		response.Checks = append(response.Checks, result)
+	}
+
+	status := http.StatusOK
+	if response.Status != models.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+func (h *HealthHandler) run(ctx context.Context, check HealthCheck) models.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Check(ctx)
+	result := models.HealthCheckResult{
+		Name:      check.Name,
+		Status:    models.HealthStatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		logging.FromContext(ctx, h.Logger).WithError(err).WithField("check", check.Name).Warn("Readiness check failed")
+		result.Status = models.HealthStatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
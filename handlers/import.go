@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importFormats maps the content types of an upload to an import format.
+var importFormats = map[string]string{
+	"text/csv":             models.ImportFormatCSV,
+	"application/x-ndjson": models.ImportFormatNDJSON,
+	"application/jsonl":    models.ImportFormatNDJSON,
+}
+
+// ImportSubscriptions imports subscriptions from CSV or NDJSON
+// @Summary Import subscriptions
+// @Description Create subscriptions from a CSV file with a header row or from NDJSON (one subscription object per line). The file is processed as a stream, every row is checked like a single create, invalid rows are reported by line and skipped. CSV headers are matched to fields by name unless mapped with mapping[header]=field
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "File format, defaults to the one of the Content-Type" Enums(csv, ndjson)
+// @Param dry_run query bool false "Only check the rows, write nothing"
+// @Param mapping query object false "CSV header to field mapping, e.g. mapping[Cost]=price"
+// @Param file body string true "CSV or NDJSON subscriptions"
+// @Success 200 {object} models.SubscriptionImportResponse
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/import [post]
+func (h *Handler) ImportSubscriptions(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = importFormats[c.ContentType()]
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		invalidParam(c, "dry_run", "must be true or false")
+		return
+	}
+
+	opts := service.ImportOptions{Format: format, Mapping: c.QueryMap("mapping"), DryRun: dryRun}
+	result, err := h.Service.Import(c.Request.Context(), c.Request.Body, opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report binding errors under the names clients send, not the Go ones.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				if name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]; name != "" && name != "-" {
+					return name
+				}
+			}
+			return field.Name
+		})
+	}
+}
+
+// problemFor maps an error to the problem describing it. Errors of other
+// types than the service ones are internal, their message is not exposed.
+func problemFor(err error) models.Problem {
+	var (
+		invalid  *service.ValidationError
+		notFound *service.NotFoundError
+		conflict *service.ConflictError
+		missing  *service.MissingRatesError
+		timeout  *service.TimeoutError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		problem := models.NewProblem(http.StatusBadRequest, models.ErrorCodeValidation, err.Error())
+		problem.Errors = invalid.Fields
+		return problem
+	case errors.As(err, &notFound):
+		return models.NewProblem(http.StatusNotFound, models.ErrorCodeNotFound, err.Error())
+	case errors.As(err, &conflict):
+		problem := models.NewProblem(http.StatusConflict, conflict.Code, err.Error())
+		problem.ConflictingID = conflict.ConflictingID
+		return problem
+	case errors.Is(err, service.ErrPreconditionFailed):
+		return models.NewProblem(http.StatusPreconditionFailed, models.ErrorCodePreconditionFailed, err.Error())
+	case errors.As(err, &missing):
+		problem := models.NewProblem(http.StatusUnprocessableEntity, models.ErrorCodeMissingRates, err.Error())
+		problem.MissingRates = missing.Rates
+		return problem
+	case errors.Is(err, service.ErrBatchRolledBack):
+		return models.NewProblem(http.StatusFailedDependency, models.ErrorCodeBatchRolledBack, err.Error())
+	case errors.As(err, &timeout) && errors.Is(timeout.Cause, context.Canceled):
+		return models.NewProblem(http.StatusServiceUnavailable, models.ErrorCodeCanceled, "The request was canceled before the database answered")
+	case errors.As(err, &timeout):
+		return models.NewProblem(http.StatusGatewayTimeout, models.ErrorCodeTimeout, "The database did not answer in time")
+	}
+	return models.NewProblem(http.StatusInternalServerError, models.ErrorCodeInternal, "The request could not be processed")
+}
+
+// respondError writes err as an application/problem+json response.
+func respondError(c *gin.Context, err error) {
+	respondProblem(c, problemFor(err))
+}
+
+// respondProblem writes problem as an application/problem+json response
+// about the requested path.
+func respondProblem(c *gin.Context, problem models.Problem) {
+	problem.Instance = c.Request.URL.Path
+	c.Header("Content-Type", models.ProblemContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// NotFound responds to a request no route or custom method matches.
+func NotFound(c *gin.Context) {
+	respondProblem(c, models.NewProblem(http.StatusNotFound, models.ErrorCodeNotFound,
+		"no "+c.Request.Method+" "+c.Request.URL.Path+" endpoint"))
+	c.Abort()
+}
+
+// invalidParam responds to an invalid path or query parameter.
+func invalidParam(c *gin.Context, name, message string) {
+	respondError(c, paramError(name, message))
+}
+
+func paramError(name, message string) error {
+	return &service.ValidationError{Fields: []models.FieldError{{Field: name, Message: message}}}
+}
+
+// invalidBody responds to a request that could not be bound, listing every
+// field that broke its binding rules.
+func invalidBody(c *gin.Context, err error) {
+	invalid := &service.ValidationError{}
+	var fieldErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &fieldErrs):
+		for _, fieldErr := range fieldErrs {
+			invalid.Fields = append(invalid.Fields, models.FieldError{
+				Field:   fieldPath(fieldErr),
+				Message: bindingMessage(fieldErr),
+			})
+		}
+	case errors.As(err, &typeErr):
+		message := "has the wrong type"
+		if kind := jsonKind(typeErr.Type); kind != "" {
+			message = "must be " + kind
+		}
+		invalid.Fields = []models.FieldError{{Field: typeErr.Field, Message: message}}
+	default:
+		invalid.Fields = []models.FieldError{{Message: "invalid request: " + err.Error()}}
+	}
+	respondError(c, invalid)
+}
+
+// fieldPath returns the path of a field below the request, as in
+// group_by[1].
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func bindingMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fieldErr.Param()
+	case "max":
+		return "must be at most " + fieldErr.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	}
+	return "is invalid"
+}
+
+// jsonKind names the JSON value expected for a Go type.
+func jsonKind(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return ""
+}
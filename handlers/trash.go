@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"em_subscription_test/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListDeletedSubscriptions lists the trash page by page with optional filters
+// @Summary List deleted subscriptions
+// @Description List subscriptions in the trash matching all given filters, one page at a time, most recently deleted first by default. Pass next_cursor of a page as cursor to get the next one with the same sort
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param user_id query []string false "User IDs, repeated or comma-separated" collectionFormat(multi)
+// @Param service_name query string false "Service name, exact match"
+// @Param service_name_prefix query string false "Service name prefix, case-insensitive"
+// @Param service_name_contains query string false "Service name substring, case-insensitive"
+// @Param active_at query string false "Active in this month, MM-YYYY"
+// @Param start_from query string false "Start date from, MM-YYYY inclusive"
+// @Param start_to query string false "Start date to, MM-YYYY inclusive"
+// @Param end_from query string false "End date from, MM-YYYY inclusive"
+// @Param end_to query string false "End date to, MM-YYYY inclusive"
+// @Param price_min query int false "Minimum current price, inclusive"
+// @Param price_max query int false "Maximum current price, inclusive"
+// @Param open_ended query bool false "Only subscriptions without end_date"
+// @Param limit query int false "Page size, 1-100" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(price, -price, start_date, -start_date, service_name, -service_name, created_at, -created_at, deleted_at, -deleted_at) default(-deleted_at)
+// @Success 200 {object} models.SubscriptionPage
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/trash [get]
+func (h *Handler) ListDeletedSubscriptions(c *gin.Context) {
+	h.listSubscriptions(c, h.Service.ListDeleted)
+}
+
+// RestoreSubscription takes a subscription out of the trash by ID
+// @Summary Restore a deleted subscription
+// @Description Take a subscription out of the trash by its ID. With strict duplicate checking it is rejected if it overlaps an active subscription of the same user and service
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription ID"
+// @Success 200 {object} models.Subscription
+// @Header 200 {string} ETag "Subscription version"
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/{id}/restore [post]
+func (h *Handler) RestoreSubscription(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.Logger).WithError(err).Error("Invalid subscription ID")
+		invalidParam(c, "id", "must be a UUID")
+		return
+	}
+
+	subscription, err := h.Service.Restore(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	setETag(c, subscription.Version)
+	c.JSON(http.StatusOK, subscription)
+}
@@ -1,79 +1,235 @@
 package app
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"em_subscription_test/config"
 	"em_subscription_test/db"
 	"em_subscription_test/handlers"
+	"em_subscription_test/internal/metrics"
+	"em_subscription_test/internal/middleware"
 	"em_subscription_test/internal/repository"
 	"em_subscription_test/internal/service"
+	"em_subscription_test/internal/worker"
+	"em_subscription_test/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/pressly/goose/v3"
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func InitializeApp() (*gin.Engine, error) {
-	cfg := config.Load()
-
-	logger := logrus.New()
-	level, err := logrus.ParseLevel(cfg.LogLevel)
-	if err != nil {
-		logrus.Printf("Invalid log level %s, using info", cfg.LogLevel)
-		level = logrus.InfoLevel
-	}
-	logger.SetLevel(level)
+// App owns the HTTP server, the database and the background workers.
+type App struct {
+	server      *http.Server
+	db          *db.DB
+	logger      *logrus.Logger
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+}
 
+// InitializeApp connects to the database, migrates it and wires the HTTP
+// server and the background workers. Every component logs with logger.
+func InitializeApp(cfg *config.Config, logger *logrus.Logger) (*App, error) {
 	database, err := db.NewDB(cfg)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to connect to database")
 		return nil, err
 	}
+	logger.Info("Connected to database")
 
 	if err := runMigrations(database.DB.DB, logger); err != nil {
-		logger.WithError(err).Fatal("Failed to run migrations")
+		_ = database.Close()
+		return nil, err
+	}
+	schemaVersion, err := latestMigration()
+	if err != nil {
+		_ = database.Close()
 		return nil, err
 	}
 
-	repo := repository.NewSubscriptionRepository(database.DB)
+	m := metrics.New(database.DB.DB, repository.NewSubscriptionRepository(database.DB, logger))
+	repo := m.InstrumentSubscriptionRepository(repository.NewSubscriptionRepository(database.DB, logger))
+	budgetRepo := repository.NewBudgetRepository(database.DB)
+	rateRepo := repository.NewExchangeRateRepository(database.DB, logger)
+	idempotencyRepo := repository.NewIdempotencyRepository(database.DB)
 
-	svc := service.NewSubscriptionService(repo, logger)
+	svc := service.NewSubscriptionService(repo, budgetRepo, rateRepo, logger, service.SubscriptionOptions{
+		StrictDuplicates: cfg.StrictDuplicates,
+		BaseCurrency:     cfg.BaseCurrency,
+	})
+	budgetSvc := service.NewBudgetService(budgetRepo, repo, rateRepo, logger, cfg.BaseCurrency)
+	rateSvc := service.NewExchangeRateService(rateRepo, logger, cfg.BaseCurrency)
 
 	h := handlers.NewHandler(svc, logger)
+	bh := handlers.NewBudgetHandler(budgetSvc, logger)
+	rh := handlers.NewExchangeRateHandler(rateSvc, logger)
 
-	g := gin.Default()
-	g.Use(gin.Logger())
-	g.Use(gin.Recovery())
+	var purger *worker.Purger
+	if cfg.TrashRetention > 0 && cfg.PurgeInterval > 0 {
+		purger = worker.NewPurger(svc, cfg.TrashRetention, cfg.PurgeInterval, logger)
+	}
+
+	checks := []handlers.HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) (interface{}, error) {
+			return nil, database.PingContext(ctx)
+		}},
+		{Name: "migrations", Check: migrationCheck(database.DB.DB, schemaVersion)},
+	}
+	if purger != nil {
+		checks = append(checks, handlers.HealthCheck{Name: "worker:purge", Check: workerCheck(purger.Status)})
+	}
+	hh := handlers.NewHealthHandler(checks, logger)
+
+	g := gin.New()
+	// Recovery comes last so that the logger and the metrics see the 500 of a
+	// panic.
+	g.Use(middleware.RequestLogger(logger))
+	g.Use(m.Middleware())
+	g.Use(middleware.Recovery(logger))
 
 	g.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	g.GET("/metrics", gin.WrapH(m.Handler()))
+	g.GET("/healthz", hh.Liveness)
+	g.GET("/readyz", hh.Readiness)
+
+	idempotent := middleware.Idempotency(idempotencyRepo, cfg.IdempotencyTTL, logger)
+
+	api := g.Group("/api/v1", middleware.Deadline(cfg.DBTimeout, map[string]time.Duration{
+		"/api/v1/subscriptions/import":  cfg.ImportTimeout,
+		"/api/v1/exchange-rates/import": cfg.ImportTimeout,
+	}))
+	custom := customMethods{
+		http.MethodPost + " subscriptions:batch":   h.BatchCreateSubscriptions,
+		http.MethodPatch + " subscriptions:batch":  h.BatchUpdateSubscriptions,
+		http.MethodDelete + " subscriptions:batch": h.BatchDeleteSubscriptions,
+	}
+	api.POST("/:custom", custom.handle)
+	api.PATCH("/:custom", custom.handle)
+	api.DELETE("/:custom", custom.handle)
 
-	api := g.Group("/api/v1")
 	subscriptions := api.Group("/subscriptions")
 	{
-		subscriptions.POST("", h.CreateSubscription)
+		subscriptions.POST("", idempotent, h.CreateSubscription)
 		subscriptions.GET("", h.ListSubscriptions)
+		subscriptions.GET("/duplicates", h.ListDuplicates)
+		subscriptions.GET("/trash", h.ListDeletedSubscriptions)
+		subscriptions.POST("/import", h.ImportSubscriptions)
 		subscriptions.GET("/:id", h.GetSubscription)
-		subscriptions.PUT("/:id", h.UpdateSubscription)
+		subscriptions.PUT("/:id", h.ReplaceSubscription)
+		subscriptions.PATCH("/:id", h.UpdateSubscription)
 		subscriptions.DELETE("/:id", h.DeleteSubscription)
-		subscriptions.POST("/total-cost", h.GetTotalCost)
+		subscriptions.POST("/:id/restore", h.RestoreSubscription)
+		subscriptions.POST("/total-cost", idempotent, h.GetTotalCost)
+		subscriptions.POST("/total-cost/monthly", h.GetMonthlyCost)
+		subscriptions.POST("/forecast", h.GetForecast)
+	}
+
+	budgets := api.Group("/budgets")
+	{
+		budgets.POST("", bh.CreateBudget)
+		budgets.GET("", bh.ListBudgets)
+		budgets.GET("/:id", bh.GetBudget)
+		budgets.PUT("/:id", bh.UpdateBudget)
+		budgets.DELETE("/:id", bh.DeleteBudget)
+		budgets.GET("/:id/status", bh.GetBudgetStatus)
+	}
+
+	api.POST("/exchange-rates/import", rh.ImportExchangeRates)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	a := &App{
+		server: &http.Server{
+			Addr:         ":" + strconv.Itoa(cfg.ServerPort),
+			Handler:      g.Handler(),
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			IdleTimeout:  cfg.IdleTimeout,
+		},
+		db:          database,
+		logger:      logger,
+		stopWorkers: stopWorkers,
+	}
+	if purger != nil {
+		a.startWorker(workerCtx, purger.Run)
 	}
 
-	return g, nil
+	return a, nil
 }
 
-func runMigrations(db *sql.DB, logger *logrus.Logger) error {
-	if err := goose.SetDialect("postgres"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %w", err)
+// Run serves HTTP requests until the server fails or Shutdown is called, in
+// which case it returns nil.
+func (a *App) Run() error {
+	a.logger.WithField("addr", a.server.Addr).Info("Starting server")
+	if err := a.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	return nil
+}
 
-	if err := goose.Up(db, "migrations"); err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, then stops the background workers and closes the database. It stops
+// waiting once ctx is done, the database is closed in any case.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := a.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain requests: %w", err))
 	}
 
-	logger.Info("Migrations completed successfully")
-	return nil
+	a.stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("failed to stop workers: %w", ctx.Err()))
+	}
+
+	if err := a.db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close database: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// startWorker runs a background worker until Shutdown.
+func (a *App) startWorker(ctx context.Context, run func(ctx context.Context)) {
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		run(ctx)
+	}()
+}
+
+// workerCheck reports the status of a background worker, which is not ready
+// once it has stopped.
+func workerCheck(status func() models.WorkerStatus) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		current := status()
+		if !current.Running {
+			return current, errors.New("worker is not running")
+		}
+		return current, nil
+	}
+}
+
+// customMethods maps "METHOD collection:method" to the handler of a custom
+// method of a collection, such as POST /subscriptions:batch. gin treats a
+// colon in a route as a path parameter, so these are matched as one.
+type customMethods map[string]gin.HandlerFunc
+
+func (m customMethods) handle(c *gin.Context) {
+	handler, ok := m[c.Request.Method+" "+c.Param("custom")]
+	if !ok {
+		handlers.NotFound(c)
+		return
+	}
+	handler(c)
 }
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"em_subscription_test/migrations"
+	"em_subscription_test/models"
+
+	"github.com/pressly/goose/v3"
+	"github.com/sirupsen/logrus"
+)
+
+func runMigrations(db *sql.DB, logger *logrus.Logger) error {
+	goose.SetBaseFS(migrations.FS)
+	goose.SetLogger(logger)
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("failed to set goose dialect: %w", err)
+	}
+
+	if err := goose.Up(db, "."); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	logger.Info("Migrations completed successfully")
+	return nil
+}
+
+// latestMigration returns the version of the newest embedded migration.
+func latestMigration() (int64, error) {
+	all, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	last, err := all.Last()
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect migrations: %w", err)
+	}
+	return last.Version, nil
+}
+
+// migrationCheck reports the schema version of the database, which is not
+// ready unless it is at the newest embedded migration.
+func migrationCheck(db *sql.DB, expected int64) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		current, err := goose.GetDBVersionContext(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		status := models.MigrationStatus{Current: current, Expected: expected}
+		if current != expected {
+			return status, fmt.Errorf("schema is at version %d, expected %d", current, expected)
+		}
+		return status, nil
+	}
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey struct{}
+
+// New creates the application logger writing with the given level and
+// format. An unknown level falls back to info and an unknown format to text,
+// with a warning.
+func New(level, format string) *logrus.Logger {
+	logger := logrus.New()
+
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	case FormatText, "":
+	default:
+		logger.WithField("log_format", format).Warn("Invalid log format, using text")
+	}
+
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		logger.WithField("log_level", level).Warn("Invalid log level, using info")
+		parsed = logrus.InfoLevel
+	}
+	logger.SetLevel(parsed)
+	return logger
+}
+
+// NewContext returns a copy of ctx carrying entry, which logs with the
+// fields of the request or worker the context belongs to.
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the logger carried by ctx, or fallback if it carries
+// none.
+func FromContext(ctx context.Context, fallback *logrus.Logger) *logrus.Entry {
+	if entry, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(fallback)
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// businessScrapeTimeout bounds the queries run for one scrape.
+const businessScrapeTimeout = 5 * time.Second
+
+var (
+	activeSubscriptionsDesc = prometheus.NewDesc("subscriptions_active",
+		"Subscriptions active in the current month.", nil, nil)
+	monthlySpendDesc = prometheus.NewDesc("subscriptions_monthly_spend",
+		"Amortized spend of the current month by service, in the currency of the subscriptions.",
+		[]string{"service_name", "currency"}, nil)
+)
+
+// businessCollector computes the business gauges from the subscriptions on
+// every scrape, so they are always current.
+type businessCollector struct {
+	repo repository.SubscriptionRepository
+}
+
+func newBusinessCollector(repo repository.SubscriptionRepository) *businessCollector {
+	return &businessCollector{repo: repo}
+}
+
+func (c *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSubscriptionsDesc
+	ch <- monthlySpendDesc
+}
+
+func (c *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), businessScrapeTimeout)
+	defer cancel()
+
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	active, err := c.repo.CountActive(ctx, month)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(activeSubscriptionsDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(activeSubscriptionsDesc, prometheus.GaugeValue, float64(active))
+	}
+
+	rows, err := c.repo.GroupedCost(ctx, models.SubscriptionFilter{}, month, month, month,
+		models.CostModeAmortized, []string{"service_name", "currency"})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(monthlySpendDesc, err)
+		return
+	}
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(monthlySpendDesc, prometheus.GaugeValue, row.Cost,
+			row.ServiceName, row.Currency)
+	}
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"em_subscription_test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that matched no route, so unknown paths do
+// not each get their own series.
+const unmatchedRoute = "unmatched"
+
+// Metrics holds the collectors exposed in the Prometheus text format.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	repositoryCalls *prometheus.CounterVec
+}
+
+// New registers the HTTP, repository and Go runtime metrics, the statistics
+// of the db pool and the business gauges computed from subscriptions on
+// every scrape.
+func New(db *sql.DB, subscriptions repository.SubscriptionRepository) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency by method, route and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repositoryCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "repository_calls_total",
+			Help: "Repository calls by repository, method and result (ok or error).",
+		}, []string{"repository", "method", "result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.repositoryCalls,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "subscriptions"),
+		newBusinessCollector(subscriptions),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text exposition format. A
+// collector that fails, such as the business gauges while the database is
+// down, is left out instead of failing the whole scrape.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// Middleware counts requests and observes their latency by route template
+// and status code. It must run outside the recovery middleware to count
+// panics as 500s.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeCall counts a repository call by its outcome.
+func (m *Metrics) observeCall(repo, method string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.repositoryCalls.WithLabelValues(repo, method, result).Inc()
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+)
+
+// InstrumentSubscriptionRepository wraps repo so that every call is counted
+// by method and result, including calls made inside a transaction.
+func (m *Metrics) InstrumentSubscriptionRepository(repo repository.SubscriptionRepository) repository.SubscriptionRepository {
+	return &instrumentedSubscriptionRepository{next: repo, metrics: m}
+}
+
+type instrumentedSubscriptionRepository struct {
+	next    repository.SubscriptionRepository
+	metrics *Metrics
+}
+
+func (r *instrumentedSubscriptionRepository) observe(method string, err error) {
+	r.metrics.observeCall("subscription", method, err)
+}
+
+func (r *instrumentedSubscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+	err := r.next.Create(ctx, subscription)
+	r.observe("Create", err)
+	return err
+}
+
+func (r *instrumentedSubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	subscription, err := r.next.GetByID(ctx, id)
+	r.observe("GetByID", err)
+	return subscription, err
+}
+
+func (r *instrumentedSubscriptionRepository) List(ctx context.Context, filter models.SubscriptionFilter,
+	opts repository.SubscriptionListOptions) ([]models.Subscription, *repository.SubscriptionCursor, error) {
+	subscriptions, next, err := r.next.List(ctx, filter, opts)
+	r.observe("List", err)
+	return subscriptions, next, err
+}
+
+func (r *instrumentedSubscriptionRepository) CountActive(ctx context.Context, month time.Time) (int, error) {
+	count, err := r.next.CountActive(ctx, month)
+	r.observe("CountActive", err)
+	return count, err
+}
+
+func (r *instrumentedSubscriptionRepository) ListOverlapping(ctx context.Context, filter models.SubscriptionFilter,
+	periodStart, periodEnd, asOf time.Time) ([]models.Subscription, error) {
+	subscriptions, err := r.next.ListOverlapping(ctx, filter, periodStart, periodEnd, asOf)
+	r.observe("ListOverlapping", err)
+	return subscriptions, err
+}
+
+func (r *instrumentedSubscriptionRepository) GroupedCost(ctx context.Context, filter models.SubscriptionFilter,
+	periodStart, periodEnd, asOf time.Time, mode string, groupBy []string) ([]repository.CostRow, error) {
+	rows, err := r.next.GroupedCost(ctx, filter, periodStart, periodEnd, asOf, mode, groupBy)
+	r.observe("GroupedCost", err)
+	return rows, err
+}
+
+func (r *instrumentedSubscriptionRepository) ListConflicting(ctx context.Context, subscription *models.Subscription) ([]models.Subscription, error) {
+	subscriptions, err := r.next.ListConflicting(ctx, subscription)
+	r.observe("ListConflicting", err)
+	return subscriptions, err
+}
+
+func (r *instrumentedSubscriptionRepository) ListDuplicates(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	subscriptions, err := r.next.ListDuplicates(ctx, filter)
+	r.observe("ListDuplicates", err)
+	return subscriptions, err
+}
+
+func (r *instrumentedSubscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+	err := r.next.Update(ctx, subscription)
+	r.observe("Update", err)
+	return err
+}
+
+func (r *instrumentedSubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, version *int) error {
+	err := r.next.Delete(ctx, id, version)
+	r.observe("Delete", err)
+	return err
+}
+
+func (r *instrumentedSubscriptionRepository) GetDeleted(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	subscription, err := r.next.GetDeleted(ctx, id)
+	r.observe("GetDeleted", err)
+	return subscription, err
+}
+
+func (r *instrumentedSubscriptionRepository) Restore(ctx context.Context, subscription *models.Subscription) error {
+	err := r.next.Restore(ctx, subscription)
+	r.observe("Restore", err)
+	return err
+}
+
+func (r *instrumentedSubscriptionRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purged, err := r.next.Purge(ctx, deletedBefore)
+	r.observe("Purge", err)
+	return purged, err
+}
+
+func (r *instrumentedSubscriptionRepository) Transaction(ctx context.Context, fn func(repo repository.SubscriptionRepository) error) error {
+	err := r.next.Transaction(ctx, func(repo repository.SubscriptionRepository) error {
+		return fn(&instrumentedSubscriptionRepository{next: repo, metrics: r.metrics})
+	})
+	r.observe("Transaction", err)
+	return err
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// answerGrace is the time left to write the response of an overridden route
+// after its deadline.
+const answerGrace = 5 * time.Second
+
+// Deadline bounds the time a request may spend on the database: its context
+// is canceled after timeout, or after the timeout given in overrides for its
+// route, such as a longer one for uploads. Queries still running then fail
+// and the request is answered with 504. The read and write deadlines of the
+// connection are moved for overridden routes too, so that the server's HTTP
+// timeouts do not cut a long upload first.
+func Deadline(timeout time.Duration, overrides map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d := timeout
+		if override, ok := overrides[c.FullPath()]; ok {
+			d = override
+			extendConnDeadlines(c.Writer, d)
+		}
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// extendConnDeadlines lets the connection of w read the request for d and
+// write the response for answerGrace more, without limit if d is 0. Writers
+// that cannot set deadlines, such as test recorders, are left alone.
+func extendConnDeadlines(w http.ResponseWriter, d time.Duration) {
+	var read, write time.Time
+	if d > 0 {
+		read = time.Now().Add(d)
+		write = read.Add(answerGrace)
+	}
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(read)
+	_ = rc.SetWriteDeadline(write)
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+// replayedHeaders are the response headers stored with an idempotent
+// response and sent again when it is replayed.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency makes a route honour the Idempotency-Key header. The first
+// response to a key is stored for ttl and replayed for repeated requests with
+// the same body. Reusing a key with another body is rejected with 422, and a
+// repeat while the first request is still running with 409. Server errors are
+// not stored, so such requests can be retried with the same key.
+func Idempotency(repo repository.IdempotencyRepository, ttl time.Duration, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			abortWithProblem(c, http.StatusBadRequest, models.ErrorCodeValidation, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithProblem(c, http.StatusBadRequest, models.ErrorCodeValidation, "The request body could not be read")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		now := time.Now()
+		record := &models.IdempotencyRecord{
+			Scope:       c.Request.Method + " " + c.FullPath(),
+			Key:         key,
+			RequestHash: hex.EncodeToString(hash[:]),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+
+		ctx := c.Request.Context()
+		reserved, err := repo.Reserve(ctx, record)
+		if err != nil {
+			logging.FromContext(ctx, logger).WithError(err).Error("Failed to reserve idempotency key")
+			abortWithProblem(c, http.StatusInternalServerError, models.ErrorCodeInternal, "The request could not be processed")
+			return
+		}
+		if !reserved {
+			replay(c, repo, record, logger)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		// The key is settled even if the request ran out of time, else it
+		// would stay in progress until it expires.
+		settleCtx := context.WithoutCancel(ctx)
+		completed := false
+		defer func() {
+			if !completed {
+				if err := repo.Release(settleCtx, record.Scope, record.Key); err != nil {
+					logging.FromContext(ctx, logger).WithError(err).Error("Failed to release idempotency key")
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		headers := make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		record.StatusCode = &status
+		record.ResponseHeaders, _ = json.Marshal(headers)
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.Complete(settleCtx, record); err != nil {
+			logging.FromContext(ctx, logger).WithError(err).Error("Failed to store idempotent response")
+			return
+		}
+		completed = true
+	}
+}
+
+// replay answers a request whose key is already taken with the stored
+// response.
+func replay(c *gin.Context, repo repository.IdempotencyRepository, record *models.IdempotencyRecord, logger *logrus.Logger) {
+	stored, err := repo.Get(c.Request.Context(), record.Scope, record.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		abortWithProblem(c, http.StatusConflict, models.ErrorCodeIdempotencyBusy, "A request with this Idempotency-Key has just finished, retry")
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context(), logger).WithError(err).Error("Failed to get idempotent response")
+		abortWithProblem(c, http.StatusInternalServerError, models.ErrorCodeInternal, "The request could not be processed")
+		return
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		abortWithProblem(c, http.StatusUnprocessableEntity, models.ErrorCodeIdempotencyMismatch, "Idempotency-Key was already used with a different request body")
+		return
+	}
+	if stored.StatusCode == nil {
+		abortWithProblem(c, http.StatusConflict, models.ErrorCodeIdempotencyBusy, "A request with this Idempotency-Key is still in progress")
+		return
+	}
+
+	var headers map[string]string
+	_ = json.Unmarshal(stored.ResponseHeaders, &headers)
+	for name, value := range headers {
+		c.Header(name, value)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(*stored.StatusCode)
+	_, _ = c.Writer.Write(stored.ResponseBody)
+	c.Abort()
+}
+
+// abortWithProblem stops the request with an RFC 7807 problem response.
+func abortWithProblem(c *gin.Context, status int, code, detail string) {
+	problem := models.NewProblem(status, code, detail)
+	problem.Instance = c.Request.URL.Path
+	c.Header("Content-Type", models.ProblemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
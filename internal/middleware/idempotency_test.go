@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeIdempotency is an in-memory IdempotencyRepository whose clock can be
+// moved forward to expire keys.
+type fakeIdempotency struct {
+	mu      sync.Mutex
+	now     time.Time
+	records map[string]models.IdempotencyRecord
+}
+
+func newFakeIdempotency() *fakeIdempotency {
+	return &fakeIdempotency{now: time.Now(), records: make(map[string]models.IdempotencyRecord)}
+}
+
+func (f *fakeIdempotency) Reserve(_ context.Context, record *models.IdempotencyRecord) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, stored := range f.records {
+		if stored.ExpiresAt.Before(f.now) {
+			delete(f.records, id)
+		}
+	}
+	id := record.Scope + " " + record.Key
+	if _, ok := f.records[id]; ok {
+		return false, nil
+	}
+	f.records[id] = *record
+	return true, nil
+}
+
+func (f *fakeIdempotency) Get(_ context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record, ok := f.records[scope+" "+key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &record, nil
+}
+
+func (f *fakeIdempotency) Complete(_ context.Context, record *models.IdempotencyRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records[record.Scope+" "+record.Key] = *record
+	return nil
+}
+
+func (f *fakeIdempotency) Release(_ context.Context, scope, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.records, scope+" "+key)
+	return nil
+}
+
+func (f *fakeIdempotency) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// idempotentRouter serves POST /items behind the Idempotency middleware with
+// handler, which counts its calls.
+func idempotentRouter(repo *fakeIdempotency, handler gin.HandlerFunc) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	calls := 0
+	var mu sync.Mutex
+	router := gin.New()
+	router.POST("/items", Idempotency(repo, time.Hour, logger), func(c *gin.Context) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		handler(c)
+	})
+	return router, &calls
+}
+
+func created(c *gin.Context) {
+	c.Header("Location", "/items/1")
+	c.JSON(http.StatusCreated, gin.H{"id": 1})
+}
+
+func post(router http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var problem models.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	return problem.Code
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	router, calls := idempotentRouter(newFakeIdempotency(), created)
+
+	first := post(router, "key-1", `{"name": "a"}`)
+	second := post(router, "key-1", `{"name": "a"}`)
+
+	if *calls != 1 {
+		t.Errorf("handler called %d times, want once", *calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() ||
+		second.Header().Get("Location") != "/items/1" || second.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("replay = %d %q %v, want the first response %d %q %v",
+			second.Code, second.Body, second.Header(), first.Code, first.Body, first.Header())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("only the replay must carry Idempotent-Replayed")
+	}
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	router, calls := idempotentRouter(newFakeIdempotency(), created)
+
+	post(router, "key-1", `{"name": "a"}`)
+	rec := post(router, "key-1", `{"name": "b"}`)
+
+	if rec.Code != http.StatusUnprocessableEntity || problemCode(t, rec) != models.ErrorCodeIdempotencyMismatch {
+		t.Errorf("status = %d, body %s, want 422 %s", rec.Code, rec.Body, models.ErrorCodeIdempotencyMismatch)
+	}
+	if *calls != 1 {
+		t.Errorf("handler called %d times, want once", *calls)
+	}
+}
+
+func TestIdempotencyRejectsConcurrentRequest(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	router, _ := idempotentRouter(newFakeIdempotency(), func(c *gin.Context) {
+		close(started)
+		<-finish
+		created(c)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(router, "key-1", `{}`) }()
+	<-started
+	rec := post(router, "key-1", `{}`)
+	close(finish)
+	first := <-done
+
+	if rec.Code != http.StatusConflict || problemCode(t, rec) != models.ErrorCodeIdempotencyBusy {
+		t.Errorf("status = %d, body %s, want 409 %s", rec.Code, rec.Body, models.ErrorCodeIdempotencyBusy)
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("first request status = %d, want 201", first.Code)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	repo := newFakeIdempotency()
+	router, calls := idempotentRouter(repo, created)
+
+	post(router, "key-1", `{"name": "a"}`)
+	repo.advance(2 * time.Hour)
+	rec := post(router, "key-1", `{"name": "b"}`)
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" || *calls != 2 {
+		t.Errorf("status = %d after expiry with %d calls, want a new 201", rec.Code, *calls)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	failures := 1
+	router, calls := idempotentRouter(newFakeIdempotency(), func(c *gin.Context) {
+		if failures > 0 {
+			failures--
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		created(c)
+	})
+
+	post(router, "key-1", `{}`)
+	rec := post(router, "key-1", `{}`)
+
+	if rec.Code != http.StatusCreated || *calls != 2 {
+		t.Errorf("retry status = %d with %d calls, want 201 from a second call", rec.Code, *calls)
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestLogger gives every request an ID, taken from the X-Request-ID header
+// or generated, and echoes it in the response. The request context carries a
+// logger with the ID, so the logs of handlers, services and repositories can
+// be correlated. Each request is logged once it is answered.
+func RequestLogger(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(requestIDHeader, id)
+
+		entry := logger.WithField("request_id", id)
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), entry))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		entry = entry.WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":  c.ClientIP(),
+			"bytes":      size,
+		})
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request failed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request rejected")
+		default:
+			entry.Info("Request handled")
+		}
+	}
+}
+
+// Recovery answers a request whose handler panicked with 500 and logs the
+// panic with the request's logger.
+func Recovery(logger *logrus.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered interface{}) {
+		logging.FromContext(c.Request.Context(), logger).WithFields(logrus.Fields{
+			"panic": fmt.Sprint(recovered),
+			"stack": string(debug.Stack()),
+		}).Error("Request panicked")
+		abortWithProblem(c, http.StatusInternalServerError, models.ErrorCodeInternal, "The request could not be processed")
+	})
+}
+
+// validRequestID reports whether a client supplied request ID is safe to
+// log and echo: short printable ASCII.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
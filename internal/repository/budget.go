@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type BudgetRepository interface {
+	Create(ctx context.Context, budget *models.Budget) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error)
+	List(ctx context.Context, filter models.BudgetFilter) ([]models.Budget, error)
+	Update(ctx context.Context, budget *models.Budget) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type budgetRepository struct {
+	db *sqlx.DB
+}
+
+func NewBudgetRepository(db *sqlx.DB) BudgetRepository {
+	return &budgetRepository{db: db}
+}
+
+func (r *budgetRepository) Create(ctx context.Context, budget *models.Budget) error {
+	query := `INSERT INTO budgets (id, user_id, service_name, monthly_limit, created_at, updated_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.ExecContext(ctx, query, budget.ID, budget.UserID, budget.ServiceName, budget.MonthlyLimit,
+		budget.CreatedAt, budget.UpdatedAt)
+	return err
+}
+
+func (r *budgetRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error) {
+	var budget models.Budget
+	query := `SELECT id, user_id, service_name, monthly_limit, created_at, updated_at
+	          FROM budgets WHERE id = $1`
+	err := r.db.GetContext(ctx, &budget, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *budgetRepository) List(ctx context.Context, filter models.BudgetFilter) ([]models.Budget, error) {
+	query := `SELECT id, user_id, service_name, monthly_limit, created_at, updated_at FROM budgets WHERE 1=1`
+	query, args := applyFilters(query, nil, filter)
+	query += " ORDER BY created_at"
+
+	var budgets []models.Budget
+	err := r.db.SelectContext(ctx, &budgets, query, args...)
+	return budgets, err
+}
+
+// Update stores budget. It returns sql.ErrNoRows if the budget does not exist.
+func (r *budgetRepository) Update(ctx context.Context, budget *models.Budget) error {
+	query := `UPDATE budgets SET user_id = $1, service_name = $2, monthly_limit = $3, updated_at = $4
+	          WHERE id = $5`
+	result, err := r.db.ExecContext(ctx, query, budget.UserID, budget.ServiceName, budget.MonthlyLimit, budget.UpdatedAt, budget.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// Delete removes the budget. It returns sql.ErrNoRows if it does not exist.
+func (r *budgetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM budgets WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// checkAffected returns sql.ErrNoRows if result changed no row.
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func applyFilters(query string, args []interface{}, filter models.BudgetFilter) (string, []interface{}) {
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		query += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	if filter.ServiceName != nil {
+		args = append(args, *filter.ServiceName)
+		query += fmt.Sprintf(" AND service_name = $%d", len(args))
+	}
+
+	return query, args
+}
@@ -0,0 +1,7 @@
+package repository
+
+import "errors"
+
+// ErrVersionConflict is returned when a row was changed since the version the
+// caller read.
+var ErrVersionConflict = errors.New("version conflict")
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+type ExchangeRateRepository interface {
+	Upsert(ctx context.Context, rates []models.ExchangeRate) error
+	List(ctx context.Context, periodStart, periodEnd time.Time) ([]models.ExchangeRate, error)
+}
+
+type exchangeRateRepository struct {
+	db     *sqlx.DB
+	logger *logrus.Logger
+}
+
+func NewExchangeRateRepository(db *sqlx.DB, logger *logrus.Logger) ExchangeRateRepository {
+	return &exchangeRateRepository{db: db, logger: logger}
+}
+
+// Upsert stores the rates in one transaction, replacing the existing rate of
+// the same currency and month.
+func (r *exchangeRateRepository) Upsert(ctx context.Context, rates []models.ExchangeRate) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO exchange_rates (currency, month, rate) VALUES ($1, $2, $3)
+	          ON CONFLICT (currency, month) DO UPDATE SET rate = EXCLUDED.rate`
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx, query, rate.Currency, rate.Month, rate.Rate); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				logging.FromContext(ctx, r.logger).WithError(rbErr).Warn("Failed to roll back exchange rates import")
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List returns the rates of every month of the period, ordered by currency
+// and month.
+func (r *exchangeRateRepository) List(ctx context.Context, periodStart, periodEnd time.Time) ([]models.ExchangeRate, error) {
+	month := monthExpr("month")
+	query := `SELECT currency, month, rate FROM exchange_rates
+	          WHERE ` + month + ` BETWEEN $1 AND $2 ORDER BY currency, ` + month
+
+	var rates []models.ExchangeRate
+	err := r.db.SelectContext(ctx, &rates, query, monthIndex(periodStart), monthIndex(periodEnd))
+	return rates, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+
+	"em_subscription_test/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, record *models.IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+	Release(ctx context.Context, scope, key string) error
+}
+
+type idempotencyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyRepository(db *sqlx.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve stores the key as in progress. It returns false if the key is
+// already taken by a record that has not expired yet.
+func (r *idempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`); err != nil {
+		return false, err
+	}
+
+	query := `INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4, $5) ON CONFLICT (scope, key) DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, record.Scope, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *idempotencyRepository) Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	query := `SELECT scope, key, request_hash, status_code, response_headers, response_body, created_at, expires_at
+	          FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	if err := r.db.GetContext(ctx, &record, query, scope, key); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response of a reserved key. The headers are passed as
+// text, a []byte would be sent as bytea.
+func (r *idempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	query := `UPDATE idempotency_keys SET status_code = $1, response_headers = $2, response_body = $3
+	          WHERE scope = $4 AND key = $5`
+	_, err := r.db.ExecContext(ctx, query, record.StatusCode, string(record.ResponseHeaders), record.ResponseBody, record.Scope, record.Key)
+	return err
+}
+
+// Release frees a reserved key so the request can be retried.
+func (r *idempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`, scope, key)
+	return err
+}
@@ -1,82 +1,623 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"em_subscription_test/internal/logging"
 	"em_subscription_test/models"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
 )
 
 type SubscriptionRepository interface {
-	Create(subscription *models.Subscription) error
-	GetByID(id uuid.UUID) (*models.Subscription, error)
-	List(filters map[string]interface{}) ([]models.Subscription, error)
-	Update(subscription *models.Subscription) error
-	Delete(id uuid.UUID) error
+	Create(ctx context.Context, subscription *models.Subscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	List(ctx context.Context, filter models.SubscriptionFilter, opts SubscriptionListOptions) ([]models.Subscription, *SubscriptionCursor, error)
+	CountActive(ctx context.Context, month time.Time) (int, error)
+	ListOverlapping(ctx context.Context, filter models.SubscriptionFilter, periodStart, periodEnd, asOf time.Time) ([]models.Subscription, error)
+	GroupedCost(ctx context.Context, filter models.SubscriptionFilter, periodStart, periodEnd, asOf time.Time, mode string, groupBy []string) ([]CostRow, error)
+	ListConflicting(ctx context.Context, subscription *models.Subscription) ([]models.Subscription, error)
+	ListDuplicates(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error)
+	Update(ctx context.Context, subscription *models.Subscription) error
+	Delete(ctx context.Context, id uuid.UUID, version *int) error
+	GetDeleted(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	Restore(ctx context.Context, subscription *models.Subscription) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	Transaction(ctx context.Context, fn func(repo SubscriptionRepository) error) error
+}
+
+const subscriptionColumns = `id, service_name, price, currency, user_id, start_date, end_date, billing_period, billing_anchor,
+	version, created_at, updated_at, deleted_at`
+
+// Dates are stored as MM-YYYY strings, so month arithmetic in SQL works on a
+// month index (year*12 + month - 1) derived from them.
+var (
+	startMonthExpr = monthExpr("start_date")
+	endMonthExpr   = monthExpr("end_date")
+)
+
+// overlapCondition matches subscriptions active in at least one month between
+// $1 and $2, treating open-ended ones as running until $3. Subscriptions in
+// the trash never match.
+var overlapCondition = fmt.Sprintf(`deleted_at IS NULL AND %[1]s <= $2 AND %[1]s <= COALESCE(%[2]s, $3) AND COALESCE(%[2]s, $3) >= $1`,
+	startMonthExpr, endMonthExpr)
+
+// anchorMonthExpr is the month index of the first charge of subscription s.
+var anchorMonthExpr = monthExpr("COALESCE(s.billing_anchor, s.start_date)")
+
+// priceExpr is the price of subscription s in month m.idx: the latest price
+// effective at or before that month, else the earliest known one.
+var priceExpr = fmt.Sprintf(`COALESCE(
+	              (SELECT p.price FROM subscription_prices p WHERE p.subscription_id = s.id AND %[1]s <= m.idx ORDER BY %[1]s DESC LIMIT 1),
+	              (SELECT p.price FROM subscription_prices p WHERE p.subscription_id = s.id ORDER BY %[1]s LIMIT 1),
+	              s.price)`, monthExpr("p.effective_from"))
+
+// chargeExprs is the amount subscription s is charged in month m.idx, from
+// its anchor month on, per cost mode, mirroring chargeAt in the service
+// layer. Cash counts a charge in the month it happens, every billing_period
+// from the anchor month (weekly charges fall on every seventh day from its
+// first day). Amortized spreads the price evenly over the months of the
+// billing period.
+var chargeExprs = map[string]string{
+	models.CostModeCash: fmt.Sprintf(`%[1]s * CASE s.billing_period
+	                  WHEN 'week' THEN floor(((%[3]s + interval '1 month')::date - 1 - %[4]s) / 7.0)
+	                                 - floor((%[3]s - 1 - %[4]s) / 7.0)
+	                  WHEN 'quarter' THEN CASE WHEN mod(mod(m.idx - %[2]s, 3) + 3, 3) = 0 THEN 1 ELSE 0 END
+	                  WHEN 'year' THEN CASE WHEN mod(mod(m.idx - %[2]s, 12) + 12, 12) = 0 THEN 1 ELSE 0 END
+	                  ELSE 1 END`,
+		priceExpr, anchorMonthExpr, monthDateExpr("m.idx"), monthDateExpr(anchorMonthExpr)),
+	models.CostModeAmortized: fmt.Sprintf(`%s * CASE s.billing_period
+	                  WHEN 'week' THEN 52.0 / 12
+	                  WHEN 'quarter' THEN 1.0 / 3
+	                  WHEN 'year' THEN 1.0 / 12
+	                  ELSE 1 END`, priceExpr),
+}
+
+// CostRow is the cost of one group returned by GroupedCost, in the currency
+// of the subscriptions. Only the fields named in groupBy are set. Cost is not
+// rounded, so that groups can be converted and summed without drifting.
+type CostRow struct {
+	ServiceName string    `db:"service_name"`
+	Currency    string    `db:"currency"`
+	UserID      uuid.UUID `db:"user_id"`
+	Month       string    `db:"month"` // MM-YYYY
+	Cost        float64   `db:"cost"`
+}
+
+// SubscriptionListOptions selects a page of subscriptions ordered by SortBy,
+// ties broken by id, starting after the After cursor. Deleted selects the
+// trash instead of the active subscriptions.
+type SubscriptionListOptions struct {
+	SortBy     string
+	Descending bool
+	After      *SubscriptionCursor
+	Limit      int
+	Deleted    bool
+}
+
+// SubscriptionCursor is the position of the last subscription of a page: its
+// value of the sort column and its id.
+type SubscriptionCursor struct {
+	Value string
+	ID    uuid.UUID
+}
+
+type sortColumn struct {
+	expr string
+	cast string
+}
+
+// subscriptionSortColumns maps a sort field to its SQL and the type its
+// cursor value is cast back to. start_date sorts by month index.
+var subscriptionSortColumns = map[string]sortColumn{
+	"price":        {"price", "int"},
+	"start_date":   {startMonthExpr, "int"},
+	"service_name": {"service_name", "text"},
+	"created_at":   {"created_at", "timestamptz"},
+	"deleted_at":   {"deleted_at", "timestamptz"},
+}
+
+type costGroupColumn struct {
+	selectExpr string
+	groupExpr  string
+}
+
+// costGroupColumns maps a group_by dimension to its SQL over the charges CTE.
+// month is grouped and ordered by the month index.
+var costGroupColumns = map[string]costGroupColumn{
+	"service_name": {"service_name", "service_name"},
+	"user_id":      {"user_id", "user_id"},
+	"currency":     {"currency", "currency"},
+	"month":        {"to_char(" + monthDateExpr("idx") + ", 'MM-YYYY') AS month", "idx"},
 }
 
 type subscriptionRepository struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	tx     *sqlx.Tx // set for the repository passed to Transaction
+	logger *logrus.Logger
 }
 
-func NewSubscriptionRepository(db *sqlx.DB) SubscriptionRepository {
-	return &subscriptionRepository{db: db}
+func NewSubscriptionRepository(db *sqlx.DB, logger *logrus.Logger) SubscriptionRepository {
+	return &subscriptionRepository{db: db, logger: logger}
 }
 
-func (r *subscriptionRepository) Create(subscription *models.Subscription) error {
-	query := `INSERT INTO subscriptions (id, service_name, price, user_id, start_date, end_date, created_at, updated_at)
-	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-	_, err := r.db.Exec(query, subscription.ID, subscription.ServiceName, subscription.Price,
-		subscription.UserID, subscription.StartDate, subscription.EndDate,
-		subscription.CreatedAt, subscription.UpdatedAt)
-	return err
+func (r *subscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		query := `INSERT INTO subscriptions (` + subscriptionColumns + `)
+		          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+		_, err := tx.ExecContext(ctx, query, subscription.ID, subscription.ServiceName, subscription.Price, subscription.Currency,
+			subscription.UserID, subscription.StartDate, subscription.EndDate,
+			subscription.BillingPeriod, subscription.BillingAnchor, subscription.Version,
+			subscription.CreatedAt, subscription.UpdatedAt, subscription.DeletedAt)
+		if err != nil {
+			return err
+		}
+		return replacePrices(ctx, tx, subscription)
+	})
 }
 
-func (r *subscriptionRepository) GetByID(id uuid.UUID) (*models.Subscription, error) {
+// GetByID returns an active subscription, sql.ErrNoRows if it does not exist
+// or is in the trash.
+func (r *subscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	return r.get(ctx, id, false)
+}
+
+// GetDeleted returns a subscription in the trash, sql.ErrNoRows if there is
+// none with that id.
+func (r *subscriptionRepository) GetDeleted(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	return r.get(ctx, id, true)
+}
+
+func (r *subscriptionRepository) get(ctx context.Context, id uuid.UUID, deleted bool) (*models.Subscription, error) {
 	var subscription models.Subscription
-	query := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at
-	          FROM subscriptions WHERE id = $1`
-	err := r.db.Get(&subscription, query, id)
+	query := `SELECT ` + subscriptionColumns + `
+	          FROM subscriptions WHERE id = $1 AND (deleted_at IS NOT NULL) = $2`
+	err := sqlx.GetContext(ctx, r.ext(), &subscription, query, id, deleted)
 	if err != nil {
 		return nil, err
 	}
-	return &subscription, nil
+
+	subscriptions := []models.Subscription{subscription}
+	if err := r.attachPrices(ctx, subscriptions); err != nil {
+		return nil, err
+	}
+	return &subscriptions[0], nil
+}
+
+// List returns a page of at most opts.Limit subscriptions and the cursor of
+// the next page, nil if this is the last one.
+func (r *subscriptionRepository) List(ctx context.Context, filter models.SubscriptionFilter, opts SubscriptionListOptions) ([]models.Subscription, *SubscriptionCursor, error) {
+	column, ok := subscriptionSortColumns[opts.SortBy]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort field %q", opts.SortBy)
+	}
+
+	query := fmt.Sprintf(`SELECT %s, (%s)::text AS sort_value FROM subscriptions WHERE (deleted_at IS NOT NULL) = $1`,
+		subscriptionColumns, column.expr)
+	query, args := applySubscriptionFilter(query, []interface{}{opts.Deleted}, filter)
+
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if opts.After != nil {
+		args = append(args, opts.After.Value, opts.After.ID)
+		query += fmt.Sprintf(" AND (%s, id) %s ($%d::%s, $%d)", column.expr, comparison, len(args)-1, column.cast, len(args))
+	}
+	args = append(args, opts.Limit+1)
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT $%[3]d", column.expr, direction, len(args))
+
+	var rows []struct {
+		models.Subscription
+		SortValue string `db:"sort_value"`
+	}
+	if err := sqlx.SelectContext(ctx, r.ext(), &rows, query, args...); err != nil {
+		return nil, nil, err
+	}
+
+	var next *SubscriptionCursor
+	if len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+		last := rows[len(rows)-1]
+		next = &SubscriptionCursor{Value: last.SortValue, ID: last.ID}
+	}
+
+	subscriptions := make([]models.Subscription, len(rows))
+	for i, row := range rows {
+		subscriptions[i] = row.Subscription
+	}
+	return subscriptions, next, nil
+}
+
+// CountActive returns the number of subscriptions active in the month.
+func (r *subscriptionRepository) CountActive(ctx context.Context, month time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM subscriptions WHERE ` + overlapCondition
+	err := sqlx.GetContext(ctx, r.ext(), &count, query, monthIndex(month), monthIndex(month), monthIndex(month))
+	return count, err
+}
+
+// ListOverlapping returns subscriptions active in at least one month of the
+// period, with their price history.
+func (r *subscriptionRepository) ListOverlapping(ctx context.Context, filter models.SubscriptionFilter, periodStart, periodEnd, asOf time.Time) ([]models.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+	          FROM subscriptions WHERE ` + overlapCondition
+	query, args := applySubscriptionFilter(query, []interface{}{monthIndex(periodStart), monthIndex(periodEnd), monthIndex(asOf)}, filter)
+
+	var subscriptions []models.Subscription
+	if err := sqlx.SelectContext(ctx, r.ext(), &subscriptions, query, args...); err != nil {
+		return nil, err
+	}
+	if err := r.attachPrices(ctx, subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
 }
 
-func (r *subscriptionRepository) List(filters map[string]interface{}) ([]models.Subscription, error) {
-	query := `SELECT id, service_name, price, user_id, start_date, end_date, created_at, updated_at FROM subscriptions WHERE 1=1`
-	args := []interface{}{}
-	argCount := 0
+// GroupedCost sums the cost of every month subscriptions are charged for in
+// the period in the given cost mode, at the price in effect in each month,
+// grouped by the given dimensions in a single query. An open-ended
+// subscription is treated as running until asOf.
+func (r *subscriptionRepository) GroupedCost(ctx context.Context, filter models.SubscriptionFilter, periodStart, periodEnd, asOf time.Time, mode string, groupBy []string) ([]CostRow, error) {
+	if len(groupBy) == 0 {
+		return nil, fmt.Errorf("at least one group_by dimension is required")
+	}
 
-	if userID, ok := filters["user_id"]; ok && userID != nil {
-		argCount++
-		query += fmt.Sprintf(" AND user_id = $%d", argCount)
-		args = append(args, userID)
+	var selects, groups []string
+	for _, dimension := range groupBy {
+		column, ok := costGroupColumns[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unknown group_by dimension %q", dimension)
+		}
+		selects = append(selects, column.selectExpr)
+		groups = append(groups, column.groupExpr)
 	}
 
-	if serviceName, ok := filters["service_name"]; ok && serviceName != nil {
-		argCount++
-		query += fmt.Sprintf(" AND service_name = $%d", argCount)
-		args = append(args, serviceName)
+	query, args, err := chargesQuery(filter, periodStart, periodEnd, asOf, mode)
+	if err != nil {
+		return nil, err
 	}
+	query += fmt.Sprintf(` SELECT %[1]s, SUM(amount)::float8 AS cost FROM charges GROUP BY %[2]s ORDER BY %[2]s`,
+		strings.Join(selects, ", "), strings.Join(groups, ", "))
+
+	var rows []CostRow
+	err = sqlx.SelectContext(ctx, r.ext(), &rows, query, args...)
+	return rows, err
+}
+
+// ListConflicting returns other active subscriptions of the same user and
+// service (case-insensitive) whose period overlaps the given one.
+func (r *subscriptionRepository) ListConflicting(ctx context.Context, subscription *models.Subscription) ([]models.Subscription, error) {
+	query := fmt.Sprintf(`SELECT `+subscriptionColumns+`
+	          FROM subscriptions
+	          WHERE user_id = $1 AND lower(service_name) = lower($2) AND id <> $3 AND deleted_at IS NULL
+	            AND (%[2]s IS NULL OR %[2]s >= %[3]s)
+	            AND (%[4]s IS NULL OR %[1]s <= %[4]s)
+	          ORDER BY %[1]s`,
+		startMonthExpr, endMonthExpr, monthExpr("$4::text"), monthExpr("$5::text"))
 
 	var subscriptions []models.Subscription
-	err := r.db.Select(&subscriptions, query, args...)
+	err := sqlx.SelectContext(ctx, r.ext(), &subscriptions, query, subscription.UserID, subscription.ServiceName, subscription.ID,
+		subscription.StartDate, subscription.EndDate)
 	return subscriptions, err
 }
 
-func (r *subscriptionRepository) Update(subscription *models.Subscription) error {
-	query := `UPDATE subscriptions SET service_name = $1, price = $2, user_id = $3,
-	          start_date = $4, end_date = $5, updated_at = $6 WHERE id = $7`
-	_, err := r.db.Exec(query, subscription.ServiceName, subscription.Price, subscription.UserID,
-		subscription.StartDate, subscription.EndDate, subscription.UpdatedAt, subscription.ID)
-	return err
+// ListDuplicates returns every active subscription that overlaps another
+// active one of the same user and service (case-insensitive), ordered so that
+// such groups are adjacent.
+func (r *subscriptionRepository) ListDuplicates(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	query := fmt.Sprintf(`SELECT `+subscriptionColumns+`
+	          FROM subscriptions a
+	          WHERE a.deleted_at IS NULL AND EXISTS (
+	              SELECT 1 FROM subscriptions b
+	              WHERE b.user_id = a.user_id AND lower(b.service_name) = lower(a.service_name) AND b.id <> a.id
+	                AND b.deleted_at IS NULL
+	                AND (%[2]s IS NULL OR %[2]s >= %[3]s)
+	                AND (%[4]s IS NULL OR %[1]s <= %[4]s)
+	          )`,
+		monthExpr("a.start_date"), monthExpr("a.end_date"), monthExpr("b.start_date"), monthExpr("b.end_date"))
+	query, args := applySubscriptionFilter(query, nil, filter)
+	query += " ORDER BY user_id, lower(service_name), " + startMonthExpr + ", id"
+
+	var subscriptions []models.Subscription
+	err := sqlx.SelectContext(ctx, r.ext(), &subscriptions, query, args...)
+	return subscriptions, err
+}
+
+// Update saves the subscription together with its price history if it is
+// still at subscription.Version, and increments the version. It returns
+// ErrVersionConflict if the row changed in between and sql.ErrNoRows if it is
+// in the trash.
+func (r *subscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		query := `UPDATE subscriptions SET service_name = $1, price = $2, currency = $3, user_id = $4,
+		          start_date = $5, end_date = $6, billing_period = $7, billing_anchor = $8, updated_at = $9,
+		          version = version + 1
+		          WHERE id = $10 AND version = $11 AND deleted_at IS NULL`
+		result, err := tx.ExecContext(ctx, query, subscription.ServiceName, subscription.Price, subscription.Currency, subscription.UserID,
+			subscription.StartDate, subscription.EndDate, subscription.BillingPeriod, subscription.BillingAnchor,
+			subscription.UpdatedAt, subscription.ID, subscription.Version)
+		if err != nil {
+			return err
+		}
+		if err := checkVersioned(ctx, tx, result, subscription.ID, false); err != nil {
+			return err
+		}
+		return replacePrices(ctx, tx, subscription)
+	})
+	if err != nil {
+		return err
+	}
+
+	subscription.Version++
+	return nil
+}
+
+// Delete moves the subscription to the trash and increments its version. It
+// returns sql.ErrNoRows if the subscription does not exist or is already in
+// the trash and, with a version, ErrVersionConflict if it is at another one.
+func (r *subscriptionRepository) Delete(ctx context.Context, id uuid.UUID, version *int) error {
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		query := `UPDATE subscriptions SET deleted_at = NOW(), version = version + 1
+		          WHERE id = $1 AND deleted_at IS NULL AND ($2::int IS NULL OR version = $2)`
+		result, err := tx.ExecContext(ctx, query, id, version)
+		if err != nil {
+			return err
+		}
+		return checkVersioned(ctx, tx, result, id, false)
+	})
+}
+
+// Restore takes the subscription out of the trash if it is still at
+// subscription.Version, and increments the version. It returns
+// ErrVersionConflict if the row changed in between and sql.ErrNoRows if it is
+// no longer in the trash.
+func (r *subscriptionRepository) Restore(ctx context.Context, subscription *models.Subscription) error {
+	err := r.inTx(ctx, func(tx *sqlx.Tx) error {
+		query := `UPDATE subscriptions SET deleted_at = NULL, updated_at = $1, version = version + 1
+		          WHERE id = $2 AND version = $3 AND deleted_at IS NOT NULL`
+		result, err := tx.ExecContext(ctx, query, subscription.UpdatedAt, subscription.ID, subscription.Version)
+		if err != nil {
+			return err
+		}
+		return checkVersioned(ctx, tx, result, subscription.ID, true)
+	})
+	if err != nil {
+		return err
+	}
+
+	subscription.DeletedAt = nil
+	subscription.Version++
+	return nil
+}
+
+// Purge permanently removes the subscriptions moved to the trash before
+// deletedBefore, together with their price history, and returns how many
+// there were.
+func (r *subscriptionRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := r.ext().ExecContext(ctx, `DELETE FROM subscriptions WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Transaction runs fn with a repository bound to a single transaction, which
+// is committed if fn returns nil. Every change made through that repository
+// is guarded by a savepoint, so a failed change is undone without aborting
+// the transaction.
+func (r *subscriptionRepository) Transaction(ctx context.Context, fn func(repo SubscriptionRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+	return r.inTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(&subscriptionRepository{db: r.db, tx: tx, logger: r.logger})
+	})
+}
+
+// ext returns the transaction the repository is bound to, else the database.
+func (r *subscriptionRepository) ext() sqlx.ExtContext {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.db
+}
+
+// inTx runs fn in a new transaction, or under a savepoint of the transaction
+// the repository is bound to.
+func (r *subscriptionRepository) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if r.tx != nil {
+		if _, err := r.tx.ExecContext(ctx, `SAVEPOINT change`); err != nil {
+			return err
+		}
+		if err := fn(r.tx); err != nil {
+			if _, rbErr := r.tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT change`); rbErr != nil && ctx.Err() == nil {
+				logging.FromContext(ctx, r.logger).WithError(rbErr).Warn("Failed to roll back to savepoint")
+			}
+			return err
+		}
+		_, err := r.tx.ExecContext(ctx, `RELEASE SAVEPOINT change`)
+		return err
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			logging.FromContext(ctx, r.logger).WithError(rbErr).Warn("Failed to roll back transaction")
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkVersioned tells why a statement guarded by a version check affected no
+// rows: ErrVersionConflict if the subscription exists, in the trash or not as
+// deleted says, else sql.ErrNoRows.
+func checkVersioned(ctx context.Context, q sqlx.QueryerContext, result sql.Result, id uuid.UUID, deleted bool) error {
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND (deleted_at IS NOT NULL) = $2)`
+	if err := sqlx.GetContext(ctx, q, &exists, query, id, deleted); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// attachPrices loads the price history of the subscriptions, ordered by the
+// month each price takes effect.
+func (r *subscriptionRepository) attachPrices(ctx context.Context, subscriptions []models.Subscription) error {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		ids[i] = sub.ID.String()
+	}
+
+	var prices []models.SubscriptionPrice
+	query := `SELECT subscription_id, effective_from, price FROM subscription_prices
+	          WHERE subscription_id = ANY($1::uuid[]) ORDER BY ` + monthExpr("effective_from")
+	if err := sqlx.SelectContext(ctx, r.ext(), &prices, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	byID := make(map[uuid.UUID][]models.SubscriptionPrice, len(subscriptions))
+	for _, price := range prices {
+		byID[price.SubscriptionID] = append(byID[price.SubscriptionID], price)
+	}
+	for i := range subscriptions {
+		subscriptions[i].Prices = byID[subscriptions[i].ID]
+	}
+	return nil
+}
+
+// replacePrices stores subscription.Prices as its full price history.
+func replacePrices(ctx context.Context, tx *sqlx.Tx, subscription *models.Subscription) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_prices WHERE subscription_id = $1`, subscription.ID); err != nil {
+		return err
+	}
+	for _, price := range subscription.Prices {
+		query := `INSERT INTO subscription_prices (subscription_id, effective_from, price) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, subscription.ID, price.EffectiveFrom, price.Price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chargesQuery starts a query with a charges CTE holding one row per
+// subscription and active month of the period from its anchor month on,
+// mirroring forEachChargedMonth in the service layer: id, service_name,
+// user_id, currency, the month index idx and the amount due in the given cost
+// mode.
+func chargesQuery(filter models.SubscriptionFilter, periodStart, periodEnd, asOf time.Time, mode string) (string, []interface{}, error) {
+	chargeExpr, ok := chargeExprs[mode]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown cost mode %q", mode)
+	}
+
+	query := fmt.Sprintf(`WITH charges AS (
+	              SELECT s.id, s.service_name, s.user_id, s.currency, m.idx, %s AS amount
+	              FROM subscriptions s
+	              JOIN generate_series($1::int, $2::int) AS m(idx) ON m.idx BETWEEN GREATEST(%s, %s) AND COALESCE(%s, $3)
+	              WHERE %s`, chargeExpr, startMonthExpr, anchorMonthExpr, endMonthExpr, overlapCondition)
+	query, args := applySubscriptionFilter(query, []interface{}{monthIndex(periodStart), monthIndex(periodEnd), monthIndex(asOf)}, filter)
+	return query + `)`, args, nil
+}
+
+// applySubscriptionFilter appends the conditions of filter to a query over
+// subscriptions ending in a WHERE clause. Dates are expected in MM-YYYY
+// format.
+func applySubscriptionFilter(query string, args []interface{}, filter models.SubscriptionFilter) (string, []interface{}) {
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	month := func(value string) string {
+		return monthExpr(arg(value) + "::text")
+	}
+
+	if len(filter.UserIDs) > 0 {
+		ids := make([]string, len(filter.UserIDs))
+		for i, id := range filter.UserIDs {
+			ids[i] = id.String()
+		}
+		query += " AND user_id = ANY(" + arg(pq.Array(ids)) + "::uuid[])"
+	}
+	if filter.ServiceName != nil {
+		query += " AND service_name = " + arg(*filter.ServiceName)
+	}
+	if filter.ServiceNamePrefix != nil {
+		query += " AND service_name ILIKE " + arg(escapeLike(*filter.ServiceNamePrefix)+"%")
+	}
+	if filter.ServiceNameContains != nil {
+		query += " AND service_name ILIKE " + arg("%"+escapeLike(*filter.ServiceNameContains)+"%")
+	}
+	if filter.ActiveAt != nil {
+		query += fmt.Sprintf(" AND %[1]s <= %[3]s AND (%[2]s IS NULL OR %[2]s >= %[3]s)",
+			startMonthExpr, endMonthExpr, month(*filter.ActiveAt))
+	}
+	if filter.StartFrom != nil {
+		query += " AND " + startMonthExpr + " >= " + month(*filter.StartFrom)
+	}
+	if filter.StartTo != nil {
+		query += " AND " + startMonthExpr + " <= " + month(*filter.StartTo)
+	}
+	if filter.EndFrom != nil {
+		query += " AND " + endMonthExpr + " >= " + month(*filter.EndFrom)
+	}
+	if filter.EndTo != nil {
+		query += " AND " + endMonthExpr + " <= " + month(*filter.EndTo)
+	}
+	if filter.PriceMin != nil {
+		query += " AND price >= " + arg(*filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		query += " AND price <= " + arg(*filter.PriceMax)
+	}
+	if filter.OpenEnded {
+		query += " AND " + endMonthExpr + " IS NULL"
+	}
+
+	return query, args
+}
+
+// escapeLike escapes the LIKE wildcards in a literal part of a pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// monthExpr converts an MM-YYYY SQL expression into a month index. NULL stays
+// NULL.
+func monthExpr(column string) string {
+	return fmt.Sprintf(`(split_part(%[1]s, '-', 2)::int * 12 + split_part(%[1]s, '-', 1)::int - 1)`, column)
+}
+
+// monthDateExpr converts a month index SQL expression into the date of the
+// first day of that month.
+func monthDateExpr(index string) string {
+	return fmt.Sprintf(`make_date((%[1]s) / 12, (%[1]s) %% 12 + 1, 1)`, index)
 }
 
-func (r *subscriptionRepository) Delete(id uuid.UUID) error {
-	query := `DELETE FROM subscriptions WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
 }
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+)
+
+func month(t *testing.T, period string) time.Time {
+	t.Helper()
+	m, err := time.Parse("01-2006", period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMonthIndex(t *testing.T) {
+	tests := []struct {
+		period string
+		want   int
+	}{
+		{"01-2024", 2024 * 12},
+		{"12-2024", 2024*12 + 11},
+		{"01-2025", 2025 * 12},
+	}
+	for _, tt := range tests {
+		if got := monthIndex(month(t, tt.period)); got != tt.want {
+			t.Errorf("monthIndex(%s) = %d, want %d", tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestChargesQuery(t *testing.T) {
+	start, end, asOf := month(t, "01-2024"), month(t, "12-2024"), month(t, "06-2024")
+	userID := uuid.New()
+	serviceName := "Netflix"
+
+	for _, mode := range []string{models.CostModeCash, models.CostModeAmortized} {
+		t.Run(mode, func(t *testing.T) {
+			query, args, err := chargesQuery(models.SubscriptionFilter{UserIDs: []uuid.UUID{userID}, ServiceName: &serviceName},
+				start, end, asOf, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(args) != 5 || args[0] != monthIndex(start) || args[1] != monthIndex(end) || args[2] != monthIndex(asOf) ||
+				args[4] != serviceName {
+				t.Errorf("args = %v, want the period, as of, user and service", args)
+			}
+			for _, part := range []string{
+				// No month before the start or the anchor is charged, and
+				// open-ended subscriptions run until as of.
+				"ON m.idx BETWEEN GREATEST(" + startMonthExpr + ", " + anchorMonthExpr + ") AND COALESCE(" + endMonthExpr + ", $3)",
+				chargeExprs[mode] + " AS amount",
+				"WHERE " + overlapCondition,
+				"AND user_id = ANY($4::uuid[])",
+				"AND service_name = $5",
+			} {
+				if !strings.Contains(query, part) {
+					t.Errorf("query lacks %q:\n%s", part, query)
+				}
+			}
+			if !strings.HasSuffix(query, ")") {
+				t.Errorf("query does not close the charges CTE:\n%s", query)
+			}
+		})
+	}
+
+	if _, _, err := chargesQuery(models.SubscriptionFilter{}, start, end, asOf, "accrual"); err == nil {
+		t.Error("unknown cost mode accepted")
+	}
+}
+
+func TestGroupedCostDimensions(t *testing.T) {
+	repo := &subscriptionRepository{}
+	start := month(t, "01-2024")
+	if _, err := repo.GroupedCost(context.Background(), models.SubscriptionFilter{}, start, start, start, models.CostModeCash, nil); err == nil {
+		t.Error("no group_by dimension accepted")
+	}
+	if _, err := repo.GroupedCost(context.Background(), models.SubscriptionFilter{}, start, start, start, models.CostModeCash, []string{"plan"}); err == nil {
+		t.Error("unknown group_by dimension accepted")
+	}
+}
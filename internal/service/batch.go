@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxBatchSize is the largest number of items accepted in one batch.
+const maxBatchSize = 1000
+
+// BatchResult is the outcome of one item of a batch. Err is nil if the item
+// was applied.
+type BatchResult struct {
+	Subscription *models.Subscription
+	Warnings     []models.BudgetWarning
+	Err          error
+}
+
+// errBatchFailed makes the transaction of an atomic batch roll back.
+var errBatchFailed = errors.New("batch item failed")
+
+// BatchCreate creates every subscription of items, see runBatch. Items are
+// checked like a single create, including the fields its binding requires.
+func (s *subscriptionService) BatchCreate(ctx context.Context, mode string, items []models.SubscriptionCreate) ([]BatchResult, error) {
+	return s.runBatch(ctx, mode, len(items), func(svc *subscriptionService, i int) BatchResult {
+		subscription, warnings, err := svc.Create(ctx, &items[i])
+		return BatchResult{Subscription: subscription, Warnings: warnings, Err: err}
+	})
+}
+
+// BatchUpdate applies every merge patch of items, see runBatch.
+func (s *subscriptionService) BatchUpdate(ctx context.Context, mode string, items []models.SubscriptionBatchPatch) ([]BatchResult, error) {
+	return s.runBatch(ctx, mode, len(items), func(svc *subscriptionService, i int) BatchResult {
+		subscription, warnings, err := svc.Update(ctx, items[i].ID, &items[i].Patch, items[i].IfMatch)
+		return BatchResult{Subscription: subscription, Warnings: warnings, Err: err}
+	})
+}
+
+// BatchDelete deletes every subscription of items, see runBatch.
+func (s *subscriptionService) BatchDelete(ctx context.Context, mode string, items []models.SubscriptionBatchDeleteItem) ([]BatchResult, error) {
+	return s.runBatch(ctx, mode, len(items), func(svc *subscriptionService, i int) BatchResult {
+		return BatchResult{Err: svc.Delete(ctx, items[i].ID, items[i].IfMatch)}
+	})
+}
+
+// runBatch applies the n items of a batch in order with the single item
+// operations. In best_effort mode each item is applied on its own. In atomic
+// mode all items are applied in one transaction; every item is still tried
+// so all failures are reported, but if any fails the transaction is rolled
+// back and the other items fail with ErrBatchRolledBack.
+func (s *subscriptionService) runBatch(ctx context.Context, mode string, n int, apply func(svc *subscriptionService, i int) BatchResult) ([]BatchResult, error) {
+	if mode == "" {
+		mode = models.BatchModeAtomic
+	}
+	if mode != models.BatchModeAtomic && mode != models.BatchModeBestEffort {
+		return nil, invalidField("mode", "must be atomic or best_effort")
+	}
+	if n == 0 || n > maxBatchSize {
+		return nil, invalidField("items", "must hold between 1 and %d entries", maxBatchSize)
+	}
+
+	results := make([]BatchResult, n)
+	if mode == models.BatchModeBestEffort {
+		for i := range results {
+			results[i] = apply(s, i)
+		}
+		return results, nil
+	}
+
+	failed := 0
+	err := s.repo.Transaction(ctx, func(repo repository.SubscriptionRepository) error {
+		svc := *s
+		svc.repo = repo
+		for i := range results {
+			results[i] = apply(&svc, i)
+			if results[i].Err != nil {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if failed > 0 {
+		if ctx.Err() != nil {
+			// The items failed for want of time, not because of their content.
+			return nil, internalError(ctx, err)
+		}
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: ErrBatchRolledBack}
+			}
+		}
+		logging.FromContext(ctx, s.logger).WithField("failed", failed).Warn("Subscription batch rolled back")
+		return results, nil
+	}
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to apply subscription batch")
+		return nil, internalError(ctx, err)
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{"mode": mode, "items": n}).Info("Subscription batch applied")
+	return results, nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"em_subscription_test/models"
+)
+
+func TestBatchCreateAtomicRollsBack(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+	items := []models.SubscriptionCreate{validCreate(), validCreate(), validCreate()}
+	items[1].Price = nil
+	items[1].StartDate = ""
+
+	results, err := svc.BatchCreate(context.Background(), models.BatchModeAtomic, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var invalid *ValidationError
+	if !errors.As(results[1].Err, &invalid) || len(invalid.Fields) != 2 ||
+		invalid.Fields[0].Field != "price" || invalid.Fields[1].Field != "start_date" {
+		t.Errorf("item 1: err = %v, want price and start_date required", results[1].Err)
+	}
+	for _, i := range []int{0, 2} {
+		if !errors.Is(results[i].Err, ErrBatchRolledBack) || results[i].Subscription != nil {
+			t.Errorf("item %d: err = %v, want ErrBatchRolledBack", i, results[i].Err)
+		}
+	}
+	if len(repo.rows) != 0 {
+		t.Errorf("stored %d subscriptions, want none", len(repo.rows))
+	}
+}
+
+func TestBatchCreateAtomic(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+
+	results, err := svc.BatchCreate(context.Background(), "", []models.SubscriptionCreate{validCreate(), validCreate()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Err != nil || result.Subscription == nil {
+			t.Errorf("item %d: err = %v, want a subscription", i, result.Err)
+		}
+	}
+	if len(repo.rows) != 2 {
+		t.Errorf("stored %d subscriptions, want 2", len(repo.rows))
+	}
+}
+
+func TestBatchCreateBestEffort(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+	items := []models.SubscriptionCreate{validCreate(), validCreate(), validCreate()}
+	items[0].ServiceName = ""
+	items[2].Price = intPtr(-1)
+
+	results, err := svc.BatchCreate(context.Background(), models.BatchModeBestEffort, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertInvalidField(t, results[0].Err, "service_name")
+	assertInvalidField(t, results[2].Err, "price")
+	if results[1].Err != nil || results[1].Subscription == nil {
+		t.Fatalf("item 1: err = %v, want a subscription", results[1].Err)
+	}
+	if _, ok := repo.rows[results[1].Subscription.ID]; !ok || len(repo.rows) != 1 {
+		t.Errorf("stored %d subscriptions, want only item 1", len(repo.rows))
+	}
+}
+
+func TestBatchLimits(t *testing.T) {
+	svc := newTestService(newFakeSubscriptions())
+	tooMany := make([]models.SubscriptionCreate, maxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = validCreate()
+	}
+
+	tests := []struct {
+		name  string
+		mode  string
+		items []models.SubscriptionCreate
+		field string
+	}{
+		{"empty", models.BatchModeAtomic, nil, "items"},
+		{"too many", models.BatchModeBestEffort, tooMany, "items"},
+		{"unknown mode", "all_or_nothing", []models.SubscriptionCreate{validCreate()}, "mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := svc.BatchCreate(context.Background(), tt.mode, tt.items)
+			assertInvalidField(t, err, tt.field)
+			if results != nil {
+				t.Errorf("results = %v, want none", results)
+			}
+		})
+	}
+
+	full := tooMany[:maxBatchSize]
+	results, err := svc.BatchCreate(context.Background(), models.BatchModeAtomic, full)
+	if err != nil || len(results) != maxBatchSize {
+		t.Errorf("batch of %d: err = %v, %d results", maxBatchSize, err, len(results))
+	}
+}
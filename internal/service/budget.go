@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// budgetHorizonMonths is how far ahead budgets are checked when no period is
+// given and for open-ended subscriptions.
+const budgetHorizonMonths = 12
+
+type BudgetService interface {
+	Create(ctx context.Context, req *models.BudgetCreate) (*models.Budget, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error)
+	List(ctx context.Context, userID *uuid.UUID) ([]models.Budget, error)
+	Update(ctx context.Context, id uuid.UUID, req *models.BudgetUpdate) (*models.Budget, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Status(ctx context.Context, id uuid.UUID, startPeriod, endPeriod string) (*models.BudgetStatus, error)
+}
+
+type budgetService struct {
+	repo          repository.BudgetRepository
+	subscriptions repository.SubscriptionRepository
+	rates         repository.ExchangeRateRepository
+	logger        *logrus.Logger
+	baseCurrency  string
+}
+
+// NewBudgetService creates the budget service. Budget limits are in
+// baseCurrency.
+func NewBudgetService(repo repository.BudgetRepository, subscriptions repository.SubscriptionRepository,
+	rates repository.ExchangeRateRepository, logger *logrus.Logger, baseCurrency string) BudgetService {
+	return &budgetService{repo: repo, subscriptions: subscriptions, rates: rates, logger: logger, baseCurrency: baseCurrency}
+}
+
+func (s *budgetService) Create(ctx context.Context, req *models.BudgetCreate) (*models.Budget, error) {
+	if req.MonthlyLimit == nil {
+		return nil, invalidField("monthly_limit", "is required")
+	}
+	if *req.MonthlyLimit < 0 {
+		return nil, invalidField("monthly_limit", "must not be negative")
+	}
+
+	budget := &models.Budget{
+		ID:           uuid.New(),
+		UserID:       req.UserID,
+		ServiceName:  req.ServiceName,
+		MonthlyLimit: *req.MonthlyLimit,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if budget.ServiceName != nil && *budget.ServiceName == "" {
+		budget.ServiceName = nil
+	}
+
+	err := s.repo.Create(ctx, budget)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to create budget")
+		return nil, internalError(ctx, err)
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"id":            budget.ID,
+		"user_id":       budget.UserID,
+		"service_name":  budget.ServiceName,
+		"monthly_limit": budget.MonthlyLimit,
+	}).Info("Budget created")
+
+	return budget, nil
+}
+
+func (s *budgetService) GetByID(ctx context.Context, id uuid.UUID) (*models.Budget, error) {
+	budget, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to get budget")
+		}
+		return nil, lookupError(ctx, err, "budget", id)
+	}
+	return budget, nil
+}
+
+func (s *budgetService) List(ctx context.Context, userID *uuid.UUID) ([]models.Budget, error) {
+	budgets, err := s.repo.List(ctx, models.BudgetFilter{UserID: userID})
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to list budgets")
+		return nil, internalError(ctx, err)
+	}
+	if budgets == nil {
+		return []models.Budget{}, nil
+	}
+	return budgets, nil
+}
+
+func (s *budgetService) Update(ctx context.Context, id uuid.UUID, req *models.BudgetUpdate) (*models.Budget, error) {
+	if req.MonthlyLimit != nil && *req.MonthlyLimit < 0 {
+		return nil, invalidField("monthly_limit", "must not be negative")
+	}
+
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, lookupError(ctx, err, "budget", id)
+	}
+
+	if req.UserID != nil {
+		existing.UserID = *req.UserID
+	}
+	if req.ServiceName != nil {
+		existing.ServiceName = req.ServiceName
+		if *req.ServiceName == "" {
+			existing.ServiceName = nil
+		}
+	}
+	if req.MonthlyLimit != nil {
+		existing.MonthlyLimit = *req.MonthlyLimit
+	}
+	existing.UpdatedAt = time.Now()
+
+	err = s.repo.Update(ctx, existing)
+	if err != nil {
+		// The budget can be deleted since it was read.
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to update budget")
+		}
+		return nil, lookupError(ctx, err, "budget", id)
+	}
+
+	logging.FromContext(ctx, s.logger).WithField("id", id).Info("Budget updated")
+	return existing, nil
+}
+
+func (s *budgetService) Delete(ctx context.Context, id uuid.UUID) error {
+	err := s.repo.Delete(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to delete budget")
+		}
+		return lookupError(ctx, err, "budget", id)
+	}
+	logging.FromContext(ctx, s.logger).WithField("id", id).Info("Budget deleted")
+	return nil
+}
+
+// Status compares the planned spend of every month in the period with the
+// budget limit. The period defaults to the current month and the following
+// eleven.
+func (s *budgetService) Status(ctx context.Context, id uuid.UUID, startPeriod, endPeriod string) (*models.BudgetStatus, error) {
+	periodStart := currentPeriod()
+	if startPeriod != "" {
+		if !isValidDateFormat(startPeriod) {
+			return nil, invalidField("start_period", "must be in MM-YYYY format")
+		}
+		periodStart, _ = parsePeriod(startPeriod)
+	}
+	periodEnd := periodStart.AddDate(0, budgetHorizonMonths-1, 0)
+	if endPeriod != "" {
+		if !isValidDateFormat(endPeriod) {
+			return nil, invalidField("end_period", "must be in MM-YYYY format")
+		}
+		periodEnd, _ = parsePeriod(endPeriod)
+	}
+	if periodStart.After(periodEnd) {
+		return nil, invalidField("start_period", "must be before or equal to end_period")
+	}
+
+	budget, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, lookupError(ctx, err, "budget", id)
+	}
+
+	costs, err := plannedCosts(ctx, s.subscriptions, s.rates, s.baseCurrency, budgetFilter(budget), periodStart, periodEnd)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to calculate budget status")
+		return nil, err
+	}
+
+	status := &models.BudgetStatus{
+		Budget:      *budget,
+		StartPeriod: formatPeriod(periodStart),
+		EndPeriod:   formatPeriod(periodEnd),
+		Months:      make([]models.BudgetMonthStatus, 0, len(costs)),
+	}
+	for i, cost := range costs {
+		status.Months = append(status.Months, models.BudgetMonthStatus{
+			Month:       formatPeriod(periodStart.AddDate(0, i, 0)),
+			Limit:       budget.MonthlyLimit,
+			PlannedCost: cost,
+			Remaining:   budget.MonthlyLimit - cost,
+			OverBudget:  cost > budget.MonthlyLimit,
+		})
+	}
+	return status, nil
+}
+
+// plannedCosts returns the planned cash spend in baseCurrency of every month
+// of the period for the subscriptions matching filter. Open-ended
+// subscriptions are assumed to run through the whole period, months without
+// exchange rates yet use the latest known ones.
+func plannedCosts(ctx context.Context, repo repository.SubscriptionRepository, rates repository.ExchangeRateRepository, baseCurrency string,
+	filter models.SubscriptionFilter, periodStart, periodEnd time.Time) ([]int, error) {
+	subscriptions, converter, err := loadPlanned(ctx, repo, rates, baseCurrency, filter, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	return sumPlanned(subscriptions, converter, periodStart, periodEnd)
+}
+
+// loadPlanned loads the subscriptions matching filter active in the period
+// and the exchange rates needed to plan their spend.
+func loadPlanned(ctx context.Context, repo repository.SubscriptionRepository, rates repository.ExchangeRateRepository, baseCurrency string,
+	filter models.SubscriptionFilter, periodStart, periodEnd time.Time) ([]models.Subscription, *currencyConverter, error) {
+	subscriptions, err := repo.ListOverlapping(ctx, filter, periodStart, periodEnd, periodEnd)
+	if err != nil {
+		return nil, nil, internalError(ctx, err)
+	}
+	converter, err := newCurrencyConverter(ctx, rates, baseCurrency, baseCurrency, true, periodStart, periodEnd)
+	if err != nil {
+		return nil, nil, internalError(ctx, err)
+	}
+	return subscriptions, converter, nil
+}
+
+// sumPlanned returns the planned cash spend of the subscriptions in every
+// month of the period.
+func sumPlanned(subscriptions []models.Subscription, converter *currencyConverter, periodStart, periodEnd time.Time) ([]int, error) {
+	amounts := make([]float64, monthDiff(periodStart, periodEnd)+1)
+	for _, sub := range subscriptions {
+		forEachChargedMonth(sub, periodStart, periodEnd, periodEnd, models.CostModeCash, func(month time.Time, cost float64) {
+			amounts[monthDiff(periodStart, month)] += converter.convert(cost, sub.Currency, month)
+		})
+	}
+	if err := converter.err(); err != nil {
+		return nil, err
+	}
+
+	costs := make([]int, len(amounts))
+	for i, amount := range amounts {
+		costs[i] = roundCost(amount)
+	}
+	return costs, nil
+}
+
+func budgetFilter(budget *models.Budget) models.SubscriptionFilter {
+	return costFilter(&budget.UserID, budget.ServiceName)
+}
+
+// budgetCovers reports whether the spend on sub counts against budget.
+func budgetCovers(budget *models.Budget, sub *models.Subscription) bool {
+	return sub.UserID == budget.UserID && (budget.ServiceName == nil || *budget.ServiceName == sub.ServiceName)
+}
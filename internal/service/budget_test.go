@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestBudgetService(repo *fakeBudgets) *budgetService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &budgetService{repo: repo, subscriptions: newFakeSubscriptions(), logger: logger, baseCurrency: "RUB"}
+}
+
+func TestCreateBudgetLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit *int
+		field string
+	}{
+		{"zero", intPtr(0), ""},
+		{"positive", intPtr(5000), ""},
+		{"negative", intPtr(-1), "monthly_limit"},
+		{"missing", nil, "monthly_limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeBudgets()
+			svc := newTestBudgetService(repo)
+
+			budget, err := svc.Create(context.Background(), &models.BudgetCreate{UserID: uuid.New(), MonthlyLimit: tt.limit})
+			if tt.field != "" {
+				assertInvalidField(t, err, tt.field)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if budget.MonthlyLimit != *tt.limit || len(repo.rows) != 1 {
+				t.Errorf("monthly_limit = %d with %d stored, want %d stored", budget.MonthlyLimit, len(repo.rows), *tt.limit)
+			}
+		})
+	}
+}
+
+// racingBudgets deletes a budget right before updating it, as a concurrent
+// request could.
+type racingBudgets struct {
+	*fakeBudgets
+}
+
+func (r racingBudgets) Update(ctx context.Context, budget *models.Budget) error {
+	if err := r.fakeBudgets.Delete(ctx, budget.ID); err != nil {
+		return err
+	}
+	return r.fakeBudgets.Update(ctx, budget)
+}
+
+func TestBudgetNotFound(t *testing.T) {
+	repo := newFakeBudgets()
+	svc := newTestBudgetService(repo)
+	ctx := context.Background()
+	var notFound *NotFoundError
+
+	if err := svc.Delete(ctx, uuid.New()); !errors.As(err, &notFound) {
+		t.Errorf("Delete of an unknown budget: err = %v, want a NotFoundError", err)
+	}
+	if _, err := svc.Update(ctx, uuid.New(), &models.BudgetUpdate{MonthlyLimit: intPtr(10)}); !errors.As(err, &notFound) {
+		t.Errorf("Update of an unknown budget: err = %v, want a NotFoundError", err)
+	}
+
+	budget, err := svc.Create(ctx, &models.BudgetCreate{UserID: uuid.New(), MonthlyLimit: intPtr(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.repo = racingBudgets{repo}
+	if _, err := svc.Update(ctx, budget.ID, &models.BudgetUpdate{MonthlyLimit: intPtr(10)}); !errors.As(err, &notFound) {
+		t.Errorf("Update of a budget deleted meanwhile: err = %v, want a NotFoundError", err)
+	}
+}
@@ -0,0 +1,512 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// weeksPerMonth spreads a weekly price over a month in amortized mode.
+const weeksPerMonth = 52.0 / 12
+
+func (s *subscriptionService) GetTotalCost(ctx context.Context, req *models.TotalCostRequest) (*models.TotalCostResponse, error) {
+	startPeriod, endPeriod, asOf, err := parseCostPeriod(req)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseCostMode(req.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGroupBy(req.GroupBy); err != nil {
+		return nil, err
+	}
+	target, err := s.targetCurrency(req.TargetCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	converter, err := newCurrencyConverter(ctx, s.rates, s.opts.BaseCurrency, target, false, startPeriod, endPeriod)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to load exchange rates")
+		return nil, internalError(ctx, err)
+	}
+
+	// Costs are converted per currency and month, so both are always grouped
+	// by, after the requested dimensions to keep the breakdown order.
+	dimensions := append(append([]string{}, req.GroupBy...), "currency")
+	if !containsString(req.GroupBy, "month") {
+		dimensions = append(dimensions, "month")
+	}
+	rows, err := s.repo.GroupedCost(ctx, costFilter(req.UserID, req.ServiceName), startPeriod, endPeriod, asOf, mode, dimensions)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to calculate total cost")
+		return nil, internalError(ctx, err)
+	}
+
+	// Costs are rounded only once summed, per group and for the total.
+	var totalCost float64
+	var groups []costGroup
+	for _, row := range rows {
+		month, _ := parsePeriod(row.Month)
+		row.Cost = converter.convert(row.Cost, row.Currency, month)
+		totalCost += row.Cost
+		addCostRow(&groups, row, req.GroupBy)
+	}
+	if err := converter.err(); err != nil {
+		return nil, err
+	}
+
+	response := &models.TotalCostResponse{
+		TotalCost: roundCost(totalCost),
+		Currency:  target,
+		Rates:     converter.usedRates(),
+	}
+	if len(req.GroupBy) > 0 {
+		response.Breakdown = costGroups(groups)
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"start_period": req.StartPeriod,
+		"end_period":   req.EndPeriod,
+		"as_of":        req.AsOf,
+		"mode":         mode,
+		"user_id":      req.UserID,
+		"service_name": req.ServiceName,
+		"group_by":     req.GroupBy,
+		"currency":     target,
+		"total_cost":   response.TotalCost,
+	}).Info("Total cost calculated")
+
+	return response, nil
+}
+
+func (s *subscriptionService) GetMonthlyCost(ctx context.Context, req *models.TotalCostRequest) (*models.MonthlyCostResponse, error) {
+	startPeriod, endPeriod, asOf, err := parseCostPeriod(req)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseCostMode(req.Mode)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.targetCurrency(req.TargetCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := s.repo.ListOverlapping(ctx, costFilter(req.UserID, req.ServiceName), startPeriod, endPeriod, asOf)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to get subscriptions for monthly cost")
+		return nil, internalError(ctx, err)
+	}
+	converter, err := newCurrencyConverter(ctx, s.rates, s.opts.BaseCurrency, target, false, startPeriod, endPeriod)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to load exchange rates")
+		return nil, internalError(ctx, err)
+	}
+
+	months := make([]models.MonthlyCost, 0, monthDiff(startPeriod, endPeriod)+1)
+	for month := startPeriod; !month.After(endPeriod); month = month.AddDate(0, 1, 0) {
+		months = append(months, models.MonthlyCost{
+			Month:           formatPeriod(month),
+			SubscriptionIDs: []uuid.UUID{},
+		})
+	}
+
+	costs := make([]float64, len(months))
+	totalCost := 0.0
+	for _, sub := range subscriptions {
+		forEachChargedMonth(sub, startPeriod, endPeriod, asOf, mode, func(month time.Time, cost float64) {
+			cost = converter.convert(cost, sub.Currency, month)
+			i := monthDiff(startPeriod, month)
+			costs[i] += cost
+			months[i].SubscriptionIDs = append(months[i].SubscriptionIDs, sub.ID)
+			totalCost += cost
+		})
+	}
+	if err := converter.err(); err != nil {
+		return nil, err
+	}
+	for i := range months {
+		months[i].Cost = roundCost(costs[i])
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"start_period": req.StartPeriod,
+		"end_period":   req.EndPeriod,
+		"as_of":        req.AsOf,
+		"mode":         mode,
+		"user_id":      req.UserID,
+		"service_name": req.ServiceName,
+		"currency":     target,
+		"total_cost":   roundCost(totalCost),
+	}).Info("Monthly cost calculated")
+
+	return &models.MonthlyCostResponse{
+		TotalCost: roundCost(totalCost),
+		Currency:  target,
+		Months:    months,
+		Rates:     converter.usedRates(),
+	}, nil
+}
+
+// Forecast projects spend for the next req.Months months in the base
+// currency. Open-ended subscriptions are assumed to keep running through the
+// whole window, known end dates, future start dates and scheduled price
+// changes are respected. Months without exchange rates yet use the latest
+// known ones.
+func (s *subscriptionService) Forecast(ctx context.Context, req *models.ForecastRequest) (*models.ForecastResponse, error) {
+	if req.Months < 1 {
+		return nil, invalidField("months", "must be at least 1")
+	}
+	mode, err := parseCostMode(req.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	startPeriod := currentPeriod().AddDate(0, 1, 0)
+	if req.From != nil {
+		if !isValidDateFormat(*req.From) {
+			return nil, invalidField("from", "must be in MM-YYYY format")
+		}
+		startPeriod, _ = parsePeriod(*req.From)
+	}
+	endPeriod := startPeriod.AddDate(0, req.Months-1, 0)
+
+	subscriptions, err := s.repo.ListOverlapping(ctx, costFilter(req.UserID, req.ServiceName), startPeriod, endPeriod, endPeriod)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to get subscriptions for forecast")
+		return nil, internalError(ctx, err)
+	}
+	converter, err := newCurrencyConverter(ctx, s.rates, s.opts.BaseCurrency, s.opts.BaseCurrency, true, startPeriod, endPeriod)
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to load exchange rates")
+		return nil, internalError(ctx, err)
+	}
+
+	monthServices := make([]map[string]float64, req.Months)
+	for i := range monthServices {
+		monthServices[i] = make(map[string]float64)
+	}
+	serviceTotals := make(map[string]float64)
+	totalCost := 0.0
+
+	response := &models.ForecastResponse{
+		StartPeriod:   formatPeriod(startPeriod),
+		EndPeriod:     formatPeriod(endPeriod),
+		Currency:      s.opts.BaseCurrency,
+		Months:        make([]models.ForecastMonth, 0, req.Months),
+		Subscriptions: make([]models.ForecastSubscription, 0, len(subscriptions)),
+	}
+
+	for _, sub := range subscriptions {
+		forecastSub := models.ForecastSubscription{
+			ID:            sub.ID,
+			ServiceName:   sub.ServiceName,
+			UserID:        sub.UserID,
+			Price:         sub.Price,
+			Currency:      sub.Currency,
+			BillingPeriod: sub.BillingPeriod,
+			StartDate:     sub.StartDate,
+			EndDate:       sub.EndDate,
+		}
+		if sub.EndDate != nil {
+			subEnd, _ := parsePeriod(*sub.EndDate)
+			forecastSub.EndsInWindow = !subEnd.Before(startPeriod) && !subEnd.After(endPeriod)
+		}
+
+		subCost := 0.0
+		forEachChargedMonth(sub, startPeriod, endPeriod, endPeriod, mode, func(month time.Time, cost float64) {
+			cost = converter.convert(cost, sub.Currency, month)
+			monthServices[monthDiff(startPeriod, month)][sub.ServiceName] += cost
+			serviceTotals[sub.ServiceName] += cost
+			subCost += cost
+			totalCost += cost
+		})
+		forecastSub.Cost = roundCost(subCost)
+		response.Subscriptions = append(response.Subscriptions, forecastSub)
+	}
+	if err := converter.err(); err != nil {
+		return nil, err
+	}
+
+	for i, services := range monthServices {
+		monthCost := 0.0
+		for _, cost := range services {
+			monthCost += cost
+		}
+		response.Months = append(response.Months, models.ForecastMonth{
+			Month:    formatPeriod(startPeriod.AddDate(0, i, 0)),
+			Cost:     roundCost(monthCost),
+			Services: serviceCosts(services),
+		})
+	}
+	response.Services = serviceCosts(serviceTotals)
+	response.TotalCost = roundCost(totalCost)
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"start_period": response.StartPeriod,
+		"end_period":   response.EndPeriod,
+		"mode":         mode,
+		"user_id":      req.UserID,
+		"service_name": req.ServiceName,
+		"total_cost":   response.TotalCost,
+	}).Info("Cost forecast calculated")
+
+	return response, nil
+}
+
+// parseCostPeriod validates the period of a cost request. as_of defaults to
+// the end of the period.
+func parseCostPeriod(req *models.TotalCostRequest) (startPeriod, endPeriod, asOf time.Time, err error) {
+	invalid := &ValidationError{}
+	for _, period := range []struct{ name, value string }{{"start_period", req.StartPeriod}, {"end_period", req.EndPeriod}} {
+		if !isValidDateFormat(period.value) {
+			invalid.Fields = append(invalid.Fields, models.FieldError{Field: period.name, Message: "must be in MM-YYYY format"})
+		}
+	}
+	if len(invalid.Fields) > 0 {
+		return startPeriod, endPeriod, asOf, invalid
+	}
+
+	startPeriod, _ = parsePeriod(req.StartPeriod)
+	endPeriod, _ = parsePeriod(req.EndPeriod)
+
+	if startPeriod.After(endPeriod) {
+		return startPeriod, endPeriod, asOf, invalidField("start_period", "must be before or equal to end_period")
+	}
+
+	asOf = endPeriod
+	if req.AsOf != nil {
+		if !isValidDateFormat(*req.AsOf) {
+			return startPeriod, endPeriod, asOf, invalidField("as_of", "must be in MM-YYYY format")
+		}
+		asOf, _ = parsePeriod(*req.AsOf)
+	}
+
+	return startPeriod, endPeriod, asOf, nil
+}
+
+// parseCostMode validates a cost mode, cash being the default.
+func parseCostMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return models.CostModeCash, nil
+	case models.CostModeCash, models.CostModeAmortized:
+		return mode, nil
+	default:
+		return "", invalidField("mode", "must be cash or amortized")
+	}
+}
+
+// targetCurrency validates the currency a cost is requested in, the base
+// currency being the default.
+func (s *subscriptionService) targetCurrency(currency string) (string, error) {
+	if currency == "" {
+		return s.opts.BaseCurrency, nil
+	}
+	currency, ok := normalizeCurrency(currency)
+	if !ok {
+		return "", invalidField("target_currency", "must be a 3-letter ISO 4217 code")
+	}
+	return currency, nil
+}
+
+func validateGroupBy(groupBy []string) error {
+	seen := make(map[string]bool, len(groupBy))
+	for _, dimension := range groupBy {
+		switch dimension {
+		case "service_name", "user_id", "month":
+		default:
+			return invalidField("group_by", "must contain only service_name, user_id or month")
+		}
+		if seen[dimension] {
+			return invalidField("group_by", "must not repeat %s", dimension)
+		}
+		seen[dimension] = true
+	}
+	return nil
+}
+
+// costGroup is a group of the breakdown while its cost is summed.
+type costGroup struct {
+	dimension string
+	key       string
+	cost      float64
+	groups    []costGroup
+}
+
+// addCostRow adds the cost of row to the nested breakdown, one level per
+// group_by dimension. Rows arrive ordered by the dimensions, so a group is
+// only ever extended while it is the last one at its level.
+func addCostRow(groups *[]costGroup, row repository.CostRow, groupBy []string) {
+	for _, dimension := range groupBy {
+		key := costRowKey(row, dimension)
+		if n := len(*groups); n == 0 || (*groups)[n-1].key != key {
+			*groups = append(*groups, costGroup{dimension: dimension, key: key})
+		}
+		group := &(*groups)[len(*groups)-1]
+		group.cost += row.Cost
+		groups = &group.groups
+	}
+}
+
+// costGroups turns the summed breakdown into the response, rounding the cost
+// of every group.
+func costGroups(groups []costGroup) []models.CostGroup {
+	result := make([]models.CostGroup, 0, len(groups))
+	for _, group := range groups {
+		converted := models.CostGroup{Dimension: group.dimension, Key: group.key, Cost: roundCost(group.cost)}
+		if len(group.groups) > 0 {
+			converted.Groups = costGroups(group.groups)
+		}
+		result = append(result, converted)
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func costRowKey(row repository.CostRow, dimension string) string {
+	switch dimension {
+	case "service_name":
+		return row.ServiceName
+	case "user_id":
+		return row.UserID.String()
+	default:
+		return row.Month
+	}
+}
+
+// serviceCosts turns per-service costs into a list sorted by service name.
+func serviceCosts(costs map[string]float64) []models.ServiceCost {
+	result := make([]models.ServiceCost, 0, len(costs))
+	for serviceName, cost := range costs {
+		result = append(result, models.ServiceCost{ServiceName: serviceName, Cost: roundCost(cost)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ServiceName < result[j].ServiceName
+	})
+	return result
+}
+
+// forEachChargedMonth calls fn for every month of the period sub is charged
+// for, with the amount charged in that month. Nothing is charged before the
+// anchor month. An open-ended subscription is treated as running until asOf.
+func forEachChargedMonth(sub models.Subscription, periodStart, periodEnd, asOf time.Time, mode string, fn func(month time.Time, cost float64)) {
+	subStart, _ := parsePeriod(sub.StartDate)
+	subEnd := asOf
+	if sub.EndDate != nil {
+		subEnd, _ = parsePeriod(*sub.EndDate)
+	}
+
+	overlapStart := maxTime(maxTime(periodStart, subStart), subscriptionAnchor(sub))
+	overlapEnd := minTime(periodEnd, subEnd)
+	for month := overlapStart; !month.After(overlapEnd); month = month.AddDate(0, 1, 0) {
+		if cost := chargeAt(sub, month, mode); cost != 0 {
+			fn(month, cost)
+		}
+	}
+}
+
+// chargeAt returns the amount sub is charged in an active month from the
+// anchor month on. In cash mode a charge is counted in the month it happens:
+// every billing_period starting from billing_anchor, weekly charges falling
+// on every seventh day from the first day of the anchor month. In amortized
+// mode the price is spread evenly over the months of its billing period.
+func chargeAt(sub models.Subscription, month time.Time, mode string) float64 {
+	price := float64(priceAt(sub, month))
+	anchor := subscriptionAnchor(sub)
+
+	switch sub.BillingPeriod {
+	case models.BillingPeriodWeek:
+		if mode == models.CostModeAmortized {
+			return price * weeksPerMonth
+		}
+		lastDay := month.AddDate(0, 1, -1)
+		charges := floorDiv(daysBetween(anchor, lastDay), 7) - floorDiv(daysBetween(anchor, month)-1, 7)
+		return price * float64(charges)
+	case models.BillingPeriodQuarter, models.BillingPeriodYear:
+		cycle := 3
+		if sub.BillingPeriod == models.BillingPeriodYear {
+			cycle = 12
+		}
+		if mode == models.CostModeAmortized {
+			return price / float64(cycle)
+		}
+		if floorMod(monthDiff(anchor, month), cycle) == 0 {
+			return price
+		}
+		return 0
+	default:
+		return price
+	}
+}
+
+// subscriptionAnchor returns the month of the first charge, start_date unless
+// billing_anchor is set.
+func subscriptionAnchor(sub models.Subscription) time.Time {
+	if sub.BillingAnchor != nil {
+		anchor, _ := parsePeriod(*sub.BillingAnchor)
+		return anchor
+	}
+	anchor, _ := parsePeriod(sub.StartDate)
+	return anchor
+}
+
+// priceAt returns the price in effect in month: the latest one effective at
+// or before it, else the earliest known one. sub.Prices must be ordered by
+// EffectiveFrom.
+func priceAt(sub models.Subscription, month time.Time) int {
+	if len(sub.Prices) == 0 {
+		return sub.Price
+	}
+
+	price := sub.Prices[0].Price
+	for _, p := range sub.Prices {
+		from, _ := parsePeriod(p.EffectiveFrom)
+		if from.After(month) {
+			break
+		}
+		price = p.Price
+	}
+	return price
+}
+
+func roundCost(cost float64) int {
+	return int(math.Round(cost))
+}
+
+// daysBetween counts whole days from a to b. Unix seconds are used because a
+// time.Duration cannot span the whole supported range of years.
+func daysBetween(a, b time.Time) int {
+	return int((b.Unix() - a.Unix()) / 86400)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/migrations"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/sirupsen/logrus"
+)
+
+func strPtr(s string) *string { return &s }
+
+// chargeCases cover every billing period with the default anchor, an anchor
+// after start_date that is not aligned to the billing period, and an anchor
+// before start_date.
+func chargeCases() []models.Subscription {
+	var cases []models.Subscription
+	periods := []string{models.BillingPeriodWeek, models.BillingPeriodMonth, models.BillingPeriodQuarter, models.BillingPeriodYear}
+	anchors := []*string{nil, strPtr("06-2024"), strPtr("11-2023")}
+	for _, period := range periods {
+		for _, anchor := range anchors {
+			for _, end := range []*string{nil, strPtr("03-2025")} {
+				cases = append(cases, models.Subscription{
+					ID:            uuid.New(),
+					ServiceName:   "Test",
+					Price:         130,
+					Currency:      "RUB",
+					UserID:        uuid.New(),
+					StartDate:     "01-2024",
+					EndDate:       end,
+					BillingPeriod: period,
+					BillingAnchor: anchor,
+					Version:       1,
+					Prices: []models.SubscriptionPrice{
+						{EffectiveFrom: "01-2024", Price: 100},
+						{EffectiveFrom: "08-2024", Price: 130},
+					},
+				})
+			}
+		}
+	}
+	return cases
+}
+
+func caseName(sub models.Subscription, mode string) string {
+	name := sub.BillingPeriod + "/" + mode + "/anchor="
+	if sub.BillingAnchor != nil {
+		name += *sub.BillingAnchor
+	}
+	name += "/end="
+	if sub.EndDate != nil {
+		name += *sub.EndDate
+	}
+	return name
+}
+
+// goCosts returns the cost of sub in every month of the period it is charged
+// for, by MM-YYYY.
+func goCosts(sub models.Subscription, periodStart, periodEnd time.Time, mode string) map[string]float64 {
+	costs := make(map[string]float64)
+	forEachChargedMonth(sub, periodStart, periodEnd, periodEnd, mode, func(month time.Time, cost float64) {
+		costs[formatPeriod(month)] += cost
+	})
+	return costs
+}
+
+func TestNoChargesBeforeAnchor(t *testing.T) {
+	periodStart, _ := parsePeriod("01-2024")
+	periodEnd, _ := parsePeriod("12-2024")
+
+	tests := []struct {
+		period string
+		mode   string
+		want   map[string]float64
+	}{
+		{models.BillingPeriodQuarter, models.CostModeCash, map[string]float64{"06-2024": 100, "09-2024": 130, "12-2024": 130}},
+		{models.BillingPeriodYear, models.CostModeCash, map[string]float64{"06-2024": 100}},
+		{models.BillingPeriodMonth, models.CostModeCash, map[string]float64{
+			"06-2024": 100, "07-2024": 100, "08-2024": 130, "09-2024": 130, "10-2024": 130, "11-2024": 130, "12-2024": 130,
+		}},
+		// Charged on 1, 8, 15, 22 and 29 June, then every seventh day.
+		{models.BillingPeriodWeek, models.CostModeCash, map[string]float64{
+			"06-2024": 500, "07-2024": 400, "08-2024": 650, "09-2024": 520, "10-2024": 520, "11-2024": 650, "12-2024": 520,
+		}},
+		{models.BillingPeriodQuarter, models.CostModeAmortized, map[string]float64{
+			"06-2024": 100.0 / 3, "07-2024": 100.0 / 3, "08-2024": 130.0 / 3, "09-2024": 130.0 / 3,
+			"10-2024": 130.0 / 3, "11-2024": 130.0 / 3, "12-2024": 130.0 / 3,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period+"/"+tt.mode, func(t *testing.T) {
+			sub := models.Subscription{
+				StartDate:     "01-2024",
+				BillingPeriod: tt.period,
+				BillingAnchor: strPtr("06-2024"),
+				Prices: []models.SubscriptionPrice{
+					{EffectiveFrom: "01-2024", Price: 100},
+					{EffectiveFrom: "08-2024", Price: 130},
+				},
+			}
+			got := goCosts(sub, periodStart, periodEnd, tt.mode)
+			if len(got) != len(tt.want) {
+				t.Fatalf("charged months = %v, want %v", got, tt.want)
+			}
+			for month, want := range tt.want {
+				if math.Abs(got[month]-want) > 1e-9 {
+					t.Errorf("%s: cost = %v, want %v", month, got[month], want)
+				}
+			}
+		})
+	}
+}
+
+// errRollback discards the data a test stored in a transaction.
+var errRollback = errors.New("rollback")
+
+// testRepository returns a repository on the PostgreSQL database given by
+// TEST_DATABASE_URL, migrated, and skips the test without one. Tests leave
+// no data behind by rolling their transactions back.
+func testRepository(t *testing.T) repository.SubscriptionRepository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	goose.SetBaseFS(migrations.FS)
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.Up(db.DB, "."); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return repository.NewSubscriptionRepository(db, logrus.New())
+}
+
+// TestTotalCostMatchesSQL checks that the total the repository sums in SQL
+// for many subscriptions equals the sum of their charges computed in Go.
+func TestTotalCostMatchesSQL(t *testing.T) {
+	repo := testRepository(t)
+	periodStart, _ := parsePeriod("01-2024")
+	periodEnd, _ := parsePeriod("06-2025")
+	asOf, _ := parsePeriod("12-2024")
+	userID := uuid.New()
+
+	for _, mode := range []string{models.CostModeCash, models.CostModeAmortized} {
+		t.Run(mode, func(t *testing.T) {
+			var want float64
+			var rows []repository.CostRow
+			err := repo.Transaction(context.Background(), func(tx repository.SubscriptionRepository) error {
+				for _, sub := range chargeCases() {
+					sub.UserID = userID
+					if err := tx.Create(context.Background(), &sub); err != nil {
+						return err
+					}
+					forEachChargedMonth(sub, periodStart, periodEnd, asOf, mode, func(_ time.Time, cost float64) {
+						want += cost
+					})
+				}
+				var err error
+				rows, err = tx.GroupedCost(context.Background(), models.SubscriptionFilter{UserIDs: []uuid.UUID{userID}},
+					periodStart, periodEnd, asOf, mode, []string{"currency"})
+				if err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				t.Fatalf("query: %v", err)
+			}
+			if len(rows) != 1 || math.Abs(rows[0].Cost-want) > 1e-6 {
+				t.Errorf("SQL total = %+v, Go total = %v", rows, want)
+			}
+		})
+	}
+}
+
+// TestChargesMatchSQL checks that the charges computed in Go and by the
+// repository's SQL agree month by month.
+func TestChargesMatchSQL(t *testing.T) {
+	repo := testRepository(t)
+	periodStart, _ := parsePeriod("01-2024")
+	periodEnd, _ := parsePeriod("06-2025")
+
+	for _, sub := range chargeCases() {
+		for _, mode := range []string{models.CostModeCash, models.CostModeAmortized} {
+			sub, mode := sub, mode
+			t.Run(caseName(sub, mode), func(t *testing.T) {
+				sub.ID = uuid.New()
+				var rows []repository.CostRow
+				err := repo.Transaction(context.Background(), func(tx repository.SubscriptionRepository) error {
+					if err := tx.Create(context.Background(), &sub); err != nil {
+						return err
+					}
+					var err error
+					rows, err = tx.GroupedCost(context.Background(), models.SubscriptionFilter{UserIDs: []uuid.UUID{sub.UserID}},
+						periodStart, periodEnd, periodEnd, mode, []string{"month"})
+					if err != nil {
+						return err
+					}
+					return errRollback
+				})
+				if !errors.Is(err, errRollback) {
+					t.Fatalf("query: %v", err)
+				}
+
+				want := goCosts(sub, periodStart, periodEnd, mode)
+				got := make(map[string]float64, len(rows))
+				for _, row := range rows {
+					if row.Cost != 0 {
+						got[row.Month] = row.Cost
+					}
+				}
+				for month := periodStart; !month.After(periodEnd); month = month.AddDate(0, 1, 0) {
+					key := formatPeriod(month)
+					if math.Abs(got[key]-want[key]) > 1e-6 {
+						t.Errorf("%s: SQL cost = %v, Go cost = %v", key, got[key], want[key])
+					}
+				}
+			})
+		}
+	}
+}
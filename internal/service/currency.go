@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+)
+
+// currencyConverter converts amounts into a target currency. Rates are the
+// value of one unit of a currency in the base currency, whose own rate is
+// always 1. It collects the rates it used and the ones it was missing.
+type currencyConverter struct {
+	base   string
+	target string
+	// latest falls back to the latest rate known at or before a month when
+	// the month itself has none, for months whose rates are not known yet.
+	latest  bool
+	rates   map[string][]models.ExchangeRate
+	seen    map[models.MissingRate]bool
+	used    []models.ExchangeRate
+	missing map[models.MissingRate]bool
+}
+
+// newCurrencyConverter loads the rates needed to convert amounts of the
+// months of the period into target.
+func newCurrencyConverter(ctx context.Context, repo repository.ExchangeRateRepository, base, target string, latest bool,
+	periodStart, periodEnd time.Time) (*currencyConverter, error) {
+	if latest {
+		periodStart = time.Time{}
+	}
+	rates, err := repo.List(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := &currencyConverter{
+		base:    base,
+		target:  target,
+		latest:  latest,
+		rates:   make(map[string][]models.ExchangeRate),
+		seen:    make(map[models.MissingRate]bool),
+		used:    []models.ExchangeRate{},
+		missing: make(map[models.MissingRate]bool),
+	}
+	for _, rate := range rates {
+		converter.rates[rate.Currency] = append(converter.rates[rate.Currency], rate)
+	}
+	return converter, nil
+}
+
+// convert returns amount of currency charged in month in the target
+// currency, or 0 if a rate is missing.
+func (c *currencyConverter) convert(amount float64, currency string, month time.Time) float64 {
+	if currency == c.target {
+		return amount
+	}
+	from, okFrom := c.rate(currency, month)
+	to, okTo := c.rate(c.target, month)
+	if !okFrom || !okTo {
+		return 0
+	}
+	return amount * from / to
+}
+
+func (c *currencyConverter) rate(currency string, month time.Time) (float64, bool) {
+	if currency == c.base {
+		return 1, true
+	}
+
+	var found *models.ExchangeRate
+	rates := c.rates[currency]
+	for i := range rates {
+		from, _ := parsePeriod(rates[i].Month)
+		if from.After(month) {
+			break
+		}
+		if c.latest || from.Equal(month) {
+			found = &rates[i]
+		}
+	}
+	if found == nil {
+		c.missing[models.MissingRate{Currency: currency, Month: formatPeriod(month)}] = true
+		return 0, false
+	}
+
+	key := models.MissingRate{Currency: found.Currency, Month: found.Month}
+	if !c.seen[key] {
+		c.seen[key] = true
+		c.used = append(c.used, *found)
+	}
+	return found.Rate, true
+}
+
+// usedRates returns the rates used so far, ordered by currency and month.
+func (c *currencyConverter) usedRates() []models.ExchangeRate {
+	sort.Slice(c.used, func(i, j int) bool {
+		return rateLess(c.used[i].Currency, c.used[i].Month, c.used[j].Currency, c.used[j].Month)
+	})
+	return c.used
+}
+
+// err reports the rates that were missing, if any.
+func (c *currencyConverter) err() error {
+	if len(c.missing) == 0 {
+		return nil
+	}
+
+	missing := make([]models.MissingRate, 0, len(c.missing))
+	for rate := range c.missing {
+		missing = append(missing, rate)
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return rateLess(missing[i].Currency, missing[i].Month, missing[j].Currency, missing[j].Month)
+	})
+	return &MissingRatesError{Rates: missing}
+}
+
+func rateLess(currencyA, monthA, currencyB, monthB string) bool {
+	if currencyA != currencyB {
+		return currencyA < currencyB
+	}
+	a, _ := parsePeriod(monthA)
+	b, _ := parsePeriod(monthB)
+	return a.Before(b)
+}
+
+// normalizeCurrency upper-cases an ISO 4217 code and reports whether it is
+// three latin letters.
+func normalizeCurrency(currency string) (string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return currency, false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return currency, false
+		}
+	}
+	return currency, true
+}
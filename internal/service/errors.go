@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+)
+
+// ValidationError is returned for invalid input, with one entry per invalid
+// field.
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = strings.TrimSpace(field.Field + " " + field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// invalidField returns a ValidationError of a single field. The message
+// follows the field name, as in "price must not be negative".
+func invalidField(field, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Fields: []models.FieldError{{Field: field, Message: fmt.Sprintf(format, args...)}}}
+}
+
+// invalidRequest returns a ValidationError of the request as a whole.
+func invalidRequest(format string, args ...interface{}) *ValidationError {
+	return invalidField("", format, args...)
+}
+
+// NotFoundError is returned when a resource does not exist.
+type NotFoundError struct {
+	Resource string // subscription, deleted subscription or budget
+	ID       uuid.UUID
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Resource, e.ID)
+}
+
+// ConflictError is returned when a change conflicts with the current state
+// of a subscription. Code is one of the models.ErrorCode constants.
+type ConflictError struct {
+	Code          string
+	Message       string
+	ConflictingID *uuid.UUID // the overlapping subscription of a duplicate
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// duplicateError is returned by Create in strict mode when the subscription
+// overlaps an existing one of the same user and service.
+func duplicateError(conflictingID uuid.UUID) *ConflictError {
+	return &ConflictError{
+		Code:          models.ErrorCodeDuplicate,
+		Message:       fmt.Sprintf("subscription overlaps existing subscription %s", conflictingID),
+		ConflictingID: &conflictingID,
+	}
+}
+
+// ErrConcurrentUpdate is returned when the subscription changed while an
+// update without If-Match was being applied. The request can be retried.
+var ErrConcurrentUpdate = &ConflictError{
+	Code:    models.ErrorCodeConcurrentUpdate,
+	Message: "subscription was modified concurrently, retry the request",
+}
+
+// ErrPreconditionFailed is returned when the version given with If-Match is
+// not the current version of the subscription.
+var ErrPreconditionFailed = errors.New("subscription has been modified since it was fetched")
+
+// ErrBatchRolledBack is the result of an item of an atomic batch that was
+// undone because another item failed.
+var ErrBatchRolledBack = errors.New("rolled back because another item of the batch failed")
+
+// InternalError wraps a failure of the storage or another dependency. Its
+// message is logged but never shown to clients.
+type InternalError struct {
+	Err error
+}
+
+func (e *InternalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when the request deadline passed, or the request
+// was canceled, before the storage answered.
+type TimeoutError struct {
+	Err   error
+	Cause error // context.DeadlineExceeded or context.Canceled
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Cause.Error() + ": " + e.Err.Error()
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// internalError wraps an unexpected error of the storage. It becomes a
+// TimeoutError if ctx is done, the driver then fails with an error of its own
+// rather than the one of ctx.
+func internalError(ctx context.Context, err error) error {
+	var timeout *TimeoutError
+	var internal *InternalError
+	switch {
+	case errors.As(err, &timeout):
+		return timeout
+	case ctx.Err() != nil:
+		return &TimeoutError{Err: err, Cause: ctx.Err()}
+	case errors.As(err, &internal):
+		return internal
+	}
+	return &InternalError{Err: err}
+}
+
+// lookupError converts an error of loading a resource by id: a missing row
+// becomes a NotFoundError, anything else an InternalError or TimeoutError.
+func lookupError(ctx context.Context, err error, resource string, id uuid.UUID) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return &NotFoundError{Resource: resource, ID: id}
+	}
+	return internalError(ctx, err)
+}
+
+// MissingRatesError is returned when a cost cannot be converted because
+// exchange rates are missing for some currencies and months.
+type MissingRatesError struct {
+	Rates []models.MissingRate
+}
+
+func (e *MissingRatesError) Error() string {
+	missing := make([]string, len(e.Rates))
+	for i, rate := range e.Rates {
+		missing[i] = rate.Currency + " " + rate.Month
+	}
+	return "missing exchange rates: " + strings.Join(missing, ", ")
+}
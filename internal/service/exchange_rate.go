@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ExchangeRateService interface {
+	Import(ctx context.Context, r io.Reader) (int, error)
+}
+
+type exchangeRateService struct {
+	repo         repository.ExchangeRateRepository
+	logger       *logrus.Logger
+	baseCurrency string
+}
+
+func NewExchangeRateService(repo repository.ExchangeRateRepository, logger *logrus.Logger, baseCurrency string) ExchangeRateService {
+	return &exchangeRateService{repo: repo, logger: logger, baseCurrency: baseCurrency}
+}
+
+// Import reads currency,month,rate rows from CSV, with an optional header
+// row, and stores them replacing existing rates of the same currency and
+// month. Nothing is stored if any row is invalid.
+func (s *exchangeRateService) Import(ctx context.Context, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, invalidRequest("invalid CSV: %s", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "currency") {
+			continue
+		}
+
+		rate, err := s.parseRate(record)
+		if err != nil {
+			return 0, invalidRequest("line %d: %s", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return 0, invalidRequest("no exchange rates to import")
+	}
+
+	if err := s.repo.Upsert(ctx, rates); err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to import exchange rates")
+		return 0, internalError(ctx, err)
+	}
+
+	logging.FromContext(ctx, s.logger).WithField("count", len(rates)).Info("Exchange rates imported")
+	return len(rates), nil
+}
+
+func (s *exchangeRateService) parseRate(record []string) (models.ExchangeRate, error) {
+	currency, ok := normalizeCurrency(record[0])
+	if !ok {
+		return models.ExchangeRate{}, invalidField("currency", "must be a 3-letter ISO 4217 code")
+	}
+	if currency == s.baseCurrency {
+		return models.ExchangeRate{}, invalidField("currency", "%s is the base currency, its rate is always 1", s.baseCurrency)
+	}
+
+	month := strings.TrimSpace(record[1])
+	if !isValidDateFormat(month) {
+		return models.ExchangeRate{}, invalidField("month", "must be in MM-YYYY format")
+	}
+	period, _ := parsePeriod(month)
+
+	rate, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+	if err != nil || !(rate > 0) || math.IsInf(rate, 1) {
+		return models.ExchangeRate{}, invalidField("rate", "must be a positive number")
+	}
+
+	return models.ExchangeRate{Currency: currency, Month: formatPeriod(period), Rate: rate}, nil
+}
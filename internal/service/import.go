@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// maxImportErrors is the number of invalid rows reported by an import, the
+// rest are only counted.
+const maxImportErrors = 1000
+
+// maxImportLineSize is the longest NDJSON line accepted.
+const maxImportLineSize = 1 << 20
+
+// ImportOptions describes an uploaded subscription file.
+type ImportOptions struct {
+	Format string // csv or ndjson
+	// Mapping maps CSV header names to subscription fields. Headers not in
+	// it are matched to fields by name, ignoring case; unknown ones are
+	// ignored.
+	Mapping map[string]string
+	DryRun  bool
+}
+
+// importFields are the fields a CSV column can be mapped to.
+var importFields = []string{"service_name", "price", "currency", "user_id", "start_date", "end_date", "billing_period", "billing_anchor"}
+
+// requiredImportFields must have a CSV column.
+var requiredImportFields = []string{"service_name", "price", "user_id", "start_date"}
+
+// Import reads subscriptions row by row from r and creates the valid ones.
+// Every row is checked like a single create. Invalid rows are reported by
+// line and skipped; a dry run only checks the rows. A ValidationError is
+// returned if the file as a whole cannot be imported: an unknown format, an
+// invalid mapping or a CSV header without a required column.
+func (s *subscriptionService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*models.SubscriptionImportResponse, error) {
+	result := &models.SubscriptionImportResponse{DryRun: opts.DryRun, Errors: []models.ImportLineError{}}
+	handle := func(line int, req *models.SubscriptionCreate, err error) error {
+		result.Total++
+		if err == nil {
+			err = s.importRow(ctx, req, opts.DryRun)
+		}
+		if err != nil {
+			if !isRowError(err) {
+				return err
+			}
+			result.Failed++
+			if len(result.Errors) < maxImportErrors {
+				result.Errors = append(result.Errors, models.ImportLineError{Line: line, Error: err.Error()})
+			} else {
+				result.ErrorsTruncated = true
+			}
+			return nil
+		}
+		result.Valid++
+		if !opts.DryRun {
+			result.Imported++
+		}
+		return nil
+	}
+
+	var err error
+	switch opts.Format {
+	case models.ImportFormatCSV:
+		err = readImportCSV(r, opts.Mapping, handle)
+	case models.ImportFormatNDJSON:
+		err = readImportNDJSON(r, handle)
+	default:
+		err = invalidField("format", "must be csv or ndjson")
+	}
+	if err != nil {
+		var invalid *ValidationError
+		if !errors.As(err, &invalid) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to import subscriptions")
+			return nil, internalError(ctx, err)
+		}
+		return nil, err
+	}
+
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+		"dry_run":  opts.DryRun,
+		"total":    result.Total,
+		"imported": result.Imported,
+		"failed":   result.Failed,
+	}).Info("Subscriptions imported")
+	return result, nil
+}
+
+// isRowError tells whether err is a problem with a single row, after which
+// the import goes on with the next one.
+func isRowError(err error) bool {
+	var invalid *ValidationError
+	var conflict *ConflictError
+	return errors.As(err, &invalid) || errors.As(err, &conflict)
+}
+
+// importRow checks one row and stores it unless dryRun. Budget warnings are
+// not checked for imported rows.
+func (s *subscriptionService) importRow(ctx context.Context, req *models.SubscriptionCreate, dryRun bool) error {
+	if err := validateCreate(req); err != nil {
+		return err
+	}
+	subscription := newSubscription(req)
+	if err := s.validateSubscription(subscription); err != nil {
+		return err
+	}
+	if err := s.checkDuplicates(ctx, subscription); err != nil {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+	if err := s.repo.Create(ctx, subscription); err != nil {
+		return internalError(ctx, err)
+	}
+	return nil
+}
+
+type importRowHandler func(line int, req *models.SubscriptionCreate, err error) error
+
+// readImportCSV reads CSV with a header row and calls handle for every data
+// row.
+func readImportCSV(r io.Reader, mapping map[string]string, handle importRowHandler) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return invalidRequest("the file is empty")
+	}
+	if err != nil {
+		return invalidRequest("invalid CSV header: %s", err)
+	}
+	// Spreadsheets often start UTF-8 files with a byte order mark.
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns, err := importColumns(header, mapping)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := handle(parseErr.StartLine, nil, invalidRequest("invalid CSV: %s", parseErr.Err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != len(header) {
+			err = invalidRequest("expected %d fields, got %d", len(header), len(record))
+			if err := handle(line, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		values := make(map[string]string, len(columns))
+		for i, field := range columns {
+			if field != "" {
+				values[field] = strings.TrimSpace(record[i])
+			}
+		}
+		req, err := importRecord(values)
+		if err := handle(line, req, err); err != nil {
+			return err
+		}
+	}
+}
+
+// importColumns returns the field of every header column, "" for ignored
+// ones.
+func importColumns(header []string, mapping map[string]string) ([]string, error) {
+	known := make(map[string]bool, len(importFields))
+	for _, field := range importFields {
+		known[field] = true
+	}
+	lowerMapping := make(map[string]string, len(mapping))
+	for name, field := range mapping {
+		if !known[field] {
+			return nil, invalidField("mapping", "maps column %q to unknown field %q", name, field)
+		}
+		lowerMapping[strings.ToLower(strings.TrimSpace(name))] = field
+	}
+
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		field, ok := lowerMapping[name]
+		if !ok && known[name] {
+			field = name
+		}
+		if field == "" {
+			continue
+		}
+		if seen[field] {
+			return nil, invalidField("mapping", "maps more than one column to %s", field)
+		}
+		seen[field] = true
+		columns[i] = field
+	}
+
+	for _, field := range requiredImportFields {
+		if !seen[field] {
+			return nil, invalidRequest("no column for %s", field)
+		}
+	}
+	return columns, nil
+}
+
+// importRecord converts the fields of a CSV row. Empty optional fields are
+// left unset.
+func importRecord(values map[string]string) (*models.SubscriptionCreate, error) {
+	req := &models.SubscriptionCreate{
+		ServiceName:   values["service_name"],
+		Currency:      values["currency"],
+		StartDate:     values["start_date"],
+		BillingPeriod: values["billing_period"],
+	}
+
+	price, err := strconv.Atoi(values["price"])
+	if err != nil {
+		return nil, invalidField("price", "must be an integer")
+	}
+	req.Price = &price
+
+	userID, err := uuid.Parse(values["user_id"])
+	if err != nil {
+		return nil, invalidField("user_id", "must be a UUID")
+	}
+	req.UserID = userID
+
+	if value := values["end_date"]; value != "" {
+		req.EndDate = &value
+	}
+	if value := values["billing_anchor"]; value != "" {
+		req.BillingAnchor = &value
+	}
+	return req, nil
+}
+
+// readImportNDJSON reads one JSON subscription per line, skipping blank
+// lines, and calls handle for each.
+func readImportNDJSON(r io.Reader, handle importRowHandler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var req models.SubscriptionCreate
+		var err error
+		if jsonErr := json.Unmarshal(data, &req); jsonErr != nil {
+			err = invalidRequest("invalid JSON: %s", jsonErr)
+		}
+		if err := handle(line, &req, err); err != nil {
+			return err
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return invalidRequest("line %d is longer than %d bytes", line+1, maxImportLineSize)
+	}
+	return scanner.Err()
+}
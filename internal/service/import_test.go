@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"em_subscription_test/models"
+)
+
+func TestImportNDJSONRequiredFields(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+	input := strings.Join([]string{
+		`{"service_name": "A", "price": 100, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"}`,
+		`{"service_name": "B", "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "07-2025"}`,
+		``,
+		`{"price": 0}`,
+		`{"service_name": "C", "price": 0, "user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "start_date": "08-2025"}`,
+	}, "\n")
+
+	result, err := svc.Import(context.Background(), strings.NewReader(input), ImportOptions{Format: models.ImportFormatNDJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 4 || result.Imported != 2 || result.Failed != 2 || len(repo.rows) != 2 {
+		t.Fatalf("result = %+v with %d stored, want 2 of 4 imported", result, len(repo.rows))
+	}
+	want := []models.ImportLineError{
+		{Line: 2, Error: "price is required"},
+		{Line: 4, Error: "service_name is required; user_id is required; start_date is required"},
+	}
+	for i, lineErr := range result.Errors {
+		if lineErr != want[i] {
+			t.Errorf("error %d = %+v, want %+v", i, lineErr, want[i])
+		}
+	}
+}
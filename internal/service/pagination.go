@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"em_subscription_test/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSubscriptionSort = "created_at"
+	defaultTrashSort        = "-deleted_at"
+	defaultPageLimit        = 50
+	maxPageLimit            = 100
+)
+
+// pageCursor is the content of an opaque next_cursor.
+type pageCursor struct {
+	Sort  string    `json:"sort"`
+	Value string    `json:"value"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func encodePageCursor(sort string, cursor *repository.SubscriptionCursor) string {
+	data, _ := json.Marshal(pageCursor{Sort: sort, Value: cursor.Value, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageCursor parses a next_cursor issued for the given sort.
+func decodePageCursor(encoded, sort string) (*repository.SubscriptionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, invalidField("cursor", "is malformed")
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, invalidField("cursor", "is malformed")
+	}
+	if cursor.Sort != sort {
+		return nil, invalidField("cursor", "was issued for sort %q", cursor.Sort)
+	}
+
+	return &repository.SubscriptionCursor{Value: cursor.Value, ID: cursor.ID}, nil
+}
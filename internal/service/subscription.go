@@ -1,13 +1,16 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
+	"em_subscription_test/internal/logging"
 	"em_subscription_test/internal/repository"
 	"em_subscription_test/models"
 
@@ -16,196 +19,650 @@ import (
 )
 
 type SubscriptionService interface {
-	Create(req *models.SubscriptionCreate) (*models.Subscription, error)
-	GetByID(id uuid.UUID) (*models.Subscription, error)
-	List(userID *uuid.UUID, serviceName *string) ([]models.Subscription, error)
-	Update(id uuid.UUID, req *models.SubscriptionUpdate) (*models.Subscription, error)
-	Delete(id uuid.UUID) error
-	GetTotalCost(req *models.TotalCostRequest) (*models.TotalCostResponse, error)
+	Create(ctx context.Context, req *models.SubscriptionCreate) (*models.Subscription, []models.BudgetWarning, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	FindDuplicates(ctx context.Context, userID *uuid.UUID, serviceName *string) ([]models.DuplicateGroup, error)
+	List(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest) (*models.SubscriptionPage, error)
+	Update(ctx context.Context, id uuid.UUID, req *models.SubscriptionUpdate, ifMatch *int) (*models.Subscription, []models.BudgetWarning, error)
+	Replace(ctx context.Context, id uuid.UUID, req *models.SubscriptionReplace, ifMatch *int) (*models.Subscription, []models.BudgetWarning, error)
+	Delete(ctx context.Context, id uuid.UUID, ifMatch *int) error
+	ListDeleted(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest) (*models.SubscriptionPage, error)
+	Restore(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+	Import(ctx context.Context, r io.Reader, opts ImportOptions) (*models.SubscriptionImportResponse, error)
+	BatchCreate(ctx context.Context, mode string, items []models.SubscriptionCreate) ([]BatchResult, error)
+	BatchUpdate(ctx context.Context, mode string, items []models.SubscriptionBatchPatch) ([]BatchResult, error)
+	BatchDelete(ctx context.Context, mode string, items []models.SubscriptionBatchDeleteItem) ([]BatchResult, error)
+	GetTotalCost(ctx context.Context, req *models.TotalCostRequest) (*models.TotalCostResponse, error)
+	GetMonthlyCost(ctx context.Context, req *models.TotalCostRequest) (*models.MonthlyCostResponse, error)
+	Forecast(ctx context.Context, req *models.ForecastRequest) (*models.ForecastResponse, error)
 }
 
-type subscriptionService struct {
-	repo   repository.SubscriptionRepository
-	logger *logrus.Logger
+// SubscriptionOptions tunes the behaviour of the subscription service.
+type SubscriptionOptions struct {
+	// StrictDuplicates makes Create reject a subscription that overlaps an
+	// existing one of the same user and service.
+	StrictDuplicates bool
+	// BaseCurrency is the currency of subscriptions created without one,
+	// exchange rates are relative to it.
+	BaseCurrency string
 }
 
-func NewSubscriptionService(repo repository.SubscriptionRepository, logger *logrus.Logger) SubscriptionService {
-	return &subscriptionService{repo: repo, logger: logger}
+type subscriptionService struct {
+	repo    repository.SubscriptionRepository
+	budgets repository.BudgetRepository
+	rates   repository.ExchangeRateRepository
+	logger  *logrus.Logger
+	opts    SubscriptionOptions
 }
 
-func (s *subscriptionService) Create(req *models.SubscriptionCreate) (*models.Subscription, error) {
-	if !isValidDateFormat(req.StartDate) {
-		return nil, fmt.Errorf("start_date must be in MM-YYYY format")
-	}
-	if req.EndDate != nil && !isValidDateFormat(*req.EndDate) {
-		return nil, fmt.Errorf("end_date must be in MM-YYYY format")
-	}
+func NewSubscriptionService(repo repository.SubscriptionRepository, budgets repository.BudgetRepository,
+	rates repository.ExchangeRateRepository, logger *logrus.Logger, opts SubscriptionOptions) SubscriptionService {
+	return &subscriptionService{repo: repo, budgets: budgets, rates: rates, logger: logger, opts: opts}
+}
 
-	subscription := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: req.ServiceName,
-		Price:       req.Price,
-		UserID:      req.UserID,
-		StartDate:   req.StartDate,
-		EndDate:     req.EndDate,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+func (s *subscriptionService) Create(ctx context.Context, req *models.SubscriptionCreate) (*models.Subscription, []models.BudgetWarning, error) {
+	subscription, err := s.prepareCreate(ctx, req)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err := s.repo.Create(subscription)
+	err = s.repo.Create(ctx, subscription)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to create subscription")
-		return nil, err
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to create subscription")
+		return nil, nil, internalError(ctx, err)
 	}
 
-	s.logger.WithFields(logrus.Fields{
+	logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
 		"id":           subscription.ID,
 		"service_name": subscription.ServiceName,
 		"user_id":      subscription.UserID,
 	}).Info("Subscription created")
 
+	return subscription, s.checkBudgets(ctx, nil, subscription), nil
+}
+
+// prepareCreate builds a new subscription from req and runs every check
+// Create does before storing it.
+func (s *subscriptionService) prepareCreate(ctx context.Context, req *models.SubscriptionCreate) (*models.Subscription, error) {
+	if err := validateCreate(req); err != nil {
+		return nil, err
+	}
+	subscription := newSubscription(req)
+	if err := s.validateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicates(ctx, subscription); err != nil {
+		return nil, err
+	}
 	return subscription, nil
 }
 
-func (s *subscriptionService) GetByID(id uuid.UUID) (*models.Subscription, error) {
-	subscription, err := s.repo.GetByID(id)
+// validateCreate reports every field the JSON binding of a single create
+// requires that is missing from req. Batch items and import lines are not
+// bound one by one, so they are checked here.
+func validateCreate(req *models.SubscriptionCreate) error {
+	var fields []models.FieldError
+	required := func(field string, missing bool) {
+		if missing {
+			fields = append(fields, models.FieldError{Field: field, Message: "is required"})
+		}
+	}
+	required("service_name", req.ServiceName == "")
+	required("price", req.Price == nil)
+	required("user_id", req.UserID == uuid.Nil)
+	required("start_date", req.StartDate == "")
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+func newSubscription(req *models.SubscriptionCreate) *models.Subscription {
+	return &models.Subscription{
+		ID:            uuid.New(),
+		ServiceName:   req.ServiceName,
+		Price:         *req.Price,
+		Currency:      req.Currency,
+		UserID:        req.UserID,
+		StartDate:     req.StartDate,
+		EndDate:       req.EndDate,
+		BillingPeriod: req.BillingPeriod,
+		BillingAnchor: req.BillingAnchor,
+		Version:       1,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		Prices:        []models.SubscriptionPrice{{EffectiveFrom: req.StartDate, Price: *req.Price}},
+	}
+}
+
+// checkDuplicates returns a duplicate ConflictError in strict mode if the new
+// subscription overlaps an existing one of the same user and service.
+func (s *subscriptionService) checkDuplicates(ctx context.Context, subscription *models.Subscription) error {
+	if !s.opts.StrictDuplicates {
+		return nil
+	}
+	conflicts, err := s.repo.ListConflicting(ctx, subscription)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("not found")
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to check for duplicate subscriptions")
+		return internalError(ctx, err)
+	}
+	if len(conflicts) > 0 {
+		return duplicateError(conflicts[0].ID)
+	}
+	return nil
+}
+
+func (s *subscriptionService) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	subscription, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to get subscription")
 		}
-		s.logger.WithError(err).Error("Failed to get subscription")
-		return nil, err
+		return nil, lookupError(ctx, err, "subscription", id)
 	}
 	return subscription, nil
 }
 
-func (s *subscriptionService) List(userID *uuid.UUID, serviceName *string) ([]models.Subscription, error) {
-	filters := make(map[string]interface{})
-	if userID != nil {
-		filters["user_id"] = *userID
+// FindDuplicates reports subscriptions of the same user and service whose
+// periods overlap, one group per user and service.
+func (s *subscriptionService) FindDuplicates(ctx context.Context, userID *uuid.UUID, serviceName *string) ([]models.DuplicateGroup, error) {
+	subscriptions, err := s.repo.ListDuplicates(ctx, costFilter(userID, serviceName))
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to find duplicate subscriptions")
+		return nil, internalError(ctx, err)
+	}
+
+	groups := []models.DuplicateGroup{}
+	for i, sub := range subscriptions {
+		if i == 0 || sub.UserID != subscriptions[i-1].UserID ||
+			!strings.EqualFold(sub.ServiceName, subscriptions[i-1].ServiceName) {
+			groups = append(groups, models.DuplicateGroup{
+				UserID:      sub.UserID,
+				ServiceName: sub.ServiceName,
+				Overlaps:    []models.SubscriptionOverlap{},
+			})
+		}
+
+		group := &groups[len(groups)-1]
+		for _, other := range group.Subscriptions {
+			if overlap, ok := subscriptionOverlap(other, sub); ok {
+				group.Overlaps = append(group.Overlaps, overlap)
+			}
+		}
+		group.Subscriptions = append(group.Subscriptions, sub)
 	}
-	if serviceName != nil {
-		filters["service_name"] = *serviceName
+
+	return groups, nil
+}
+
+// List returns a page of subscriptions in keyset order. The cursor of the
+// next page carries the sort it was issued for and cannot be reused with
+// another one.
+func (s *subscriptionService) List(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest) (*models.SubscriptionPage, error) {
+	return s.list(ctx, filter, page, false)
+}
+
+// ListDeleted returns a page of the trash like List, most recently deleted
+// first by default.
+func (s *subscriptionService) ListDeleted(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest) (*models.SubscriptionPage, error) {
+	return s.list(ctx, filter, page, true)
+}
+
+func (s *subscriptionService) list(ctx context.Context, filter *models.SubscriptionFilter, page *models.SubscriptionPageRequest, deleted bool) (*models.SubscriptionPage, error) {
+	if err := validateSubscriptionFilter(filter); err != nil {
+		return nil, err
 	}
 
-	subscriptions, err := s.repo.List(filters)
+	sort := page.Sort
+	if sort == "" {
+		sort = defaultSubscriptionSort
+		if deleted {
+			sort = defaultTrashSort
+		}
+	}
+	opts := repository.SubscriptionListOptions{
+		SortBy:     strings.TrimPrefix(sort, "-"),
+		Descending: strings.HasPrefix(sort, "-"),
+		Limit:      page.Limit,
+		Deleted:    deleted,
+	}
+	switch {
+	case opts.SortBy == "price", opts.SortBy == "start_date", opts.SortBy == "service_name", opts.SortBy == "created_at":
+	case opts.SortBy == "deleted_at" && deleted:
+	case deleted:
+		return nil, invalidField("sort", "must be price, start_date, service_name, created_at or deleted_at, optionally prefixed with -")
+	default:
+		return nil, invalidField("sort", "must be price, start_date, service_name or created_at, optionally prefixed with -")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = defaultPageLimit
+	}
+	if opts.Limit < 1 || opts.Limit > maxPageLimit {
+		return nil, invalidField("limit", "must be between 1 and %d", maxPageLimit)
+	}
+	if page.Cursor != "" {
+		cursor, err := decodePageCursor(page.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		opts.After = cursor
+	}
+
+	subscriptions, next, err := s.repo.List(ctx, *filter, opts)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to list subscriptions")
-		return nil, err
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to list subscriptions")
+		return nil, internalError(ctx, err)
 	}
-	if subscriptions == nil {
-		return []models.Subscription{}, nil
+
+	result := &models.SubscriptionPage{Items: subscriptions}
+	if result.Items == nil {
+		result.Items = []models.Subscription{}
+	}
+	if next != nil {
+		cursor := encodePageCursor(sort, next)
+		result.NextCursor = &cursor
 	}
-	return subscriptions, nil
+	return result, nil
 }
 
-func (s *subscriptionService) Update(id uuid.UUID, req *models.SubscriptionUpdate) (*models.Subscription, error) {
-	if req.StartDate != nil && !isValidDateFormat(*req.StartDate) {
-		return nil, fmt.Errorf("start_date must be in MM-YYYY format")
+// Update applies a JSON Merge Patch to the subscription. The patched
+// subscription is validated as a whole. A non-nil ifMatch is the version the
+// client expects the subscription to be at.
+func (s *subscriptionService) Update(ctx context.Context, id uuid.UUID, req *models.SubscriptionUpdate, ifMatch *int) (*models.Subscription, []models.BudgetWarning, error) {
+	required := []struct {
+		name string
+		null bool
+	}{
+		{"service_name", req.ServiceName.Null},
+		{"price", req.Price.Null},
+		{"currency", req.Currency.Null},
+		{"user_id", req.UserID.Null},
+		{"start_date", req.StartDate.Null},
+		{"billing_period", req.BillingPeriod.Null},
+	}
+	for _, field := range required {
+		if field.null {
+			return nil, nil, invalidField(field.name, "cannot be null")
+		}
 	}
-	if req.EndDate != nil && *req.EndDate != "" && !isValidDateFormat(*req.EndDate) {
-		return nil, fmt.Errorf("end_date must be in MM-YYYY format")
+	if req.PriceEffectiveFrom != nil && !req.Price.Set {
+		return nil, nil, invalidField("price_effective_from", "requires price")
 	}
 
-	existing, err := s.repo.GetByID(id)
+	existing, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, nil, lookupError(ctx, err, "subscription", id)
+	}
+	if ifMatch != nil && *ifMatch != existing.Version {
+		return nil, nil, ErrPreconditionFailed
 	}
 
-	if req.ServiceName != nil {
-		existing.ServiceName = *req.ServiceName
+	updated := *existing
+	if req.ServiceName.Set {
+		updated.ServiceName = req.ServiceName.Value
 	}
-	if req.Price != nil {
-		existing.Price = *req.Price
+	if req.Currency.Set {
+		updated.Currency = req.Currency.Value
 	}
-	if req.UserID != nil {
-		existing.UserID = *req.UserID
+	if req.UserID.Set {
+		updated.UserID = req.UserID.Value
 	}
-	if req.StartDate != nil {
-		existing.StartDate = *req.StartDate
+	if req.StartDate.Set {
+		updated.StartDate = req.StartDate.Value
 	}
-	if req.EndDate != nil {
-		existing.EndDate = req.EndDate
+	if req.EndDate.Set {
+		updated.EndDate = req.EndDate.Ptr()
+	}
+	if req.BillingPeriod.Set {
+		updated.BillingPeriod = req.BillingPeriod.Value
+	}
+	if req.BillingAnchor.Set {
+		updated.BillingAnchor = req.BillingAnchor.Ptr()
+	}
+	if req.Price.Set {
+		if err := applyPrice(&updated, req.Price.Value, req.PriceEffectiveFrom); err != nil {
+			return nil, nil, err
+		}
 	}
-	existing.UpdatedAt = time.Now()
 
-	err = s.repo.Update(existing)
+	return s.save(ctx, existing, &updated, ifMatch)
+}
+
+// Replace overwrites every field of the subscription. Omitted optional fields
+// are reset to their defaults. The price history is kept when the price does
+// not change.
+func (s *subscriptionService) Replace(ctx context.Context, id uuid.UUID, req *models.SubscriptionReplace, ifMatch *int) (*models.Subscription, []models.BudgetWarning, error) {
+	if req.Price == nil {
+		return nil, nil, invalidField("price", "is required")
+	}
+	existing, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to update subscription")
-		return nil, err
+		return nil, nil, lookupError(ctx, err, "subscription", id)
+	}
+	if ifMatch != nil && *ifMatch != existing.Version {
+		return nil, nil, ErrPreconditionFailed
+	}
+
+	replaced := *existing
+	replaced.ServiceName = req.ServiceName
+	replaced.Currency = req.Currency
+	replaced.UserID = req.UserID
+	replaced.StartDate = req.StartDate
+	replaced.EndDate = req.EndDate
+	replaced.BillingPeriod = req.BillingPeriod
+	replaced.BillingAnchor = req.BillingAnchor
+	if err := applyPrice(&replaced, *req.Price, req.PriceEffectiveFrom); err != nil {
+		return nil, nil, err
 	}
 
-	s.logger.WithField("id", id).Info("Subscription updated")
-	return existing, nil
+	return s.save(ctx, existing, &replaced, ifMatch)
 }
 
-func (s *subscriptionService) Delete(id uuid.UUID) error {
-	err := s.repo.Delete(id)
+// save validates and stores a changed subscription unless it changed since it
+// was read as existing.
+func (s *subscriptionService) save(ctx context.Context, existing, subscription *models.Subscription, ifMatch *int) (*models.Subscription, []models.BudgetWarning, error) {
+	if err := s.validateSubscription(subscription); err != nil {
+		return nil, nil, err
+	}
+	subscription.UpdatedAt = time.Now()
+
+	err := s.repo.Update(ctx, subscription)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to delete subscription")
-		return err
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if ifMatch != nil {
+				return nil, nil, ErrPreconditionFailed
+			}
+			return nil, nil, ErrConcurrentUpdate
+		}
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to update subscription")
+		return nil, nil, internalError(ctx, err)
+	}
+
+	logging.FromContext(ctx, s.logger).WithField("id", subscription.ID).Info("Subscription updated")
+	return subscription, s.checkBudgets(ctx, existing, subscription), nil
+}
+
+// validateSubscription checks every field of a subscription about to be
+// stored, filling in the default currency and billing period.
+func (s *subscriptionService) validateSubscription(sub *models.Subscription) error {
+	if strings.TrimSpace(sub.ServiceName) == "" {
+		return invalidField("service_name", "must not be empty")
+	}
+	if sub.Price < 0 {
+		return invalidField("price", "must not be negative")
+	}
+	if sub.UserID == uuid.Nil {
+		return invalidField("user_id", "is required")
+	}
+
+	if sub.Currency == "" {
+		sub.Currency = s.opts.BaseCurrency
+	}
+	currency, ok := normalizeCurrency(sub.Currency)
+	if !ok {
+		return invalidField("currency", "must be a 3-letter ISO 4217 code")
+	}
+	sub.Currency = currency
+
+	if !isValidDateFormat(sub.StartDate) {
+		return invalidField("start_date", "must be in MM-YYYY format")
+	}
+	if sub.EndDate != nil {
+		if !isValidDateFormat(*sub.EndDate) {
+			return invalidField("end_date", "must be in MM-YYYY format")
+		}
+		start, _ := parsePeriod(sub.StartDate)
+		end, _ := parsePeriod(*sub.EndDate)
+		if end.Before(start) {
+			return invalidField("end_date", "must not be before start_date")
+		}
+	}
+
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = models.BillingPeriodMonth
+	}
+	if !isValidBillingPeriod(sub.BillingPeriod) {
+		return invalidField("billing_period", "must be one of week, month, quarter or year")
+	}
+	if sub.BillingAnchor != nil && !isValidDateFormat(*sub.BillingAnchor) {
+		return invalidField("billing_anchor", "must be in MM-YYYY format")
 	}
-	s.logger.WithField("id", id).Info("Subscription deleted")
 	return nil
 }
 
-func (s *subscriptionService) GetTotalCost(req *models.TotalCostRequest) (*models.TotalCostResponse, error) {
-	if !isValidDateFormat(req.StartPeriod) || !isValidDateFormat(req.EndPeriod) {
-		return nil, fmt.Errorf("start_period and end_period must be in MM-YYYY format")
+// applyPrice sets the price of sub. With effectiveFrom the price is charged
+// from that month on, else from the current month, kept within the months
+// the subscription is active, so the price history and past costs are kept.
+func applyPrice(sub *models.Subscription, price int, effectiveFrom *string) error {
+	if price < 0 {
+		return invalidField("price", "must not be negative")
 	}
 
-	startPeriod, err := parsePeriod(req.StartPeriod)
+	switch {
+	case effectiveFrom != nil:
+		if !isValidDateFormat(*effectiveFrom) {
+			return invalidField("price_effective_from", "must be in MM-YYYY format")
+		}
+		sub.Prices = setPrice(sub.Prices, *effectiveFrom, price)
+	case len(sub.Prices) == 0:
+		sub.Prices = []models.SubscriptionPrice{{EffectiveFrom: sub.StartDate, Price: price}}
+	case price != sub.Price:
+		sub.Prices = setPrice(sub.Prices, defaultPriceEffectiveFrom(sub), price)
+	}
+	sub.Price = sub.Prices[len(sub.Prices)-1].Price
+	return nil
+}
+
+// defaultPriceEffectiveFrom returns the month a price change without an
+// explicit date takes effect: the current month, or the first or last month
+// of the subscription if it is not active now.
+func defaultPriceEffectiveFrom(sub *models.Subscription) string {
+	if !isValidDateFormat(sub.StartDate) {
+		// Rejected by validateSubscription.
+		return sub.StartDate
+	}
+	from := currentPeriod()
+	if start, _ := parsePeriod(sub.StartDate); from.Before(start) {
+		from = start
+	}
+	if sub.EndDate != nil && isValidDateFormat(*sub.EndDate) {
+		if end, _ := parsePeriod(*sub.EndDate); from.After(end) {
+			from = end
+		}
+	}
+	return formatPeriod(from)
+}
+
+// Delete moves the subscription to the trash, from which it can be restored
+// until it is purged. A non-nil ifMatch is the version the client expects the
+// subscription to be at.
+func (s *subscriptionService) Delete(ctx context.Context, id uuid.UUID, ifMatch *int) error {
+	err := s.repo.Delete(ctx, id, ifMatch)
 	if err != nil {
-		return nil, fmt.Errorf("invalid start_period")
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return ErrPreconditionFailed
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return &NotFoundError{Resource: "subscription", ID: id}
+		}
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to delete subscription")
+		return internalError(ctx, err)
 	}
-	endPeriod, err := parsePeriod(req.EndPeriod)
+	logging.FromContext(ctx, s.logger).WithField("id", id).Info("Subscription deleted")
+	return nil
+}
+
+// Restore takes the subscription out of the trash. With strict duplicate
+// checking it is rejected if it overlaps an active subscription of the same
+// user and service.
+func (s *subscriptionService) Restore(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	subscription, err := s.repo.GetDeleted(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid end_period")
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to get deleted subscription")
+		}
+		return nil, lookupError(ctx, err, "deleted subscription", id)
+	}
+	if err := s.checkDuplicates(ctx, subscription); err != nil {
+		return nil, err
 	}
 
-	if startPeriod.After(endPeriod) {
-		return nil, fmt.Errorf("start_period must be before or equal to end_period")
+	subscription.UpdatedAt = time.Now()
+	if err := s.repo.Restore(ctx, subscription); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrConcurrentUpdate
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &NotFoundError{Resource: "deleted subscription", ID: id}
+		}
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to restore subscription")
+		return nil, internalError(ctx, err)
 	}
 
-	filters := make(map[string]interface{})
-	if req.UserID != nil {
-		filters["user_id"] = *req.UserID
+	logging.FromContext(ctx, s.logger).WithField("id", id).Info("Subscription restored")
+	return subscription, nil
+}
+
+// PurgeDeleted permanently removes the subscriptions that have been in the
+// trash for longer than retention and returns how many there were.
+func (s *subscriptionService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		logging.FromContext(ctx, s.logger).WithError(err).Error("Failed to purge deleted subscriptions")
+		return 0, internalError(ctx, err)
 	}
-	if req.ServiceName != nil {
-		filters["service_name"] = *req.ServiceName
+	if purged > 0 {
+		logging.FromContext(ctx, s.logger).WithField("count", purged).Info("Deleted subscriptions purged")
 	}
+	return purged, nil
+}
 
-	subscriptions, err := s.repo.List(filters)
+// checkBudgets returns a warning for every budget of the subscription's user
+// that the change from before to sub makes exceed its limit in a month sub is
+// charged for; before is nil for a new subscription. Budgets that were
+// already exceeded in a month are not warned about again. Only months from
+// the current one onwards are checked. Failures are logged and never block
+// the change.
+func (s *subscriptionService) checkBudgets(ctx context.Context, before, sub *models.Subscription) []models.BudgetWarning {
+	userBudgets, err := s.budgets.List(ctx, models.BudgetFilter{UserID: &sub.UserID})
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get subscriptions for total cost")
-		return nil, err
+		logging.FromContext(ctx, s.logger).WithError(err).Warn("Failed to check budgets")
+		return nil
+	}
+
+	subStart, _ := parsePeriod(sub.StartDate)
+	windowStart := maxTime(currentPeriod(), subStart)
+	windowEnd := windowStart.AddDate(0, budgetHorizonMonths-1, 0)
+	if sub.EndDate != nil {
+		subEnd, _ := parsePeriod(*sub.EndDate)
+		windowEnd = minTime(windowEnd, subEnd)
+	}
+	if windowStart.After(windowEnd) {
+		return nil
 	}
 
-	totalCost := 0
-	for _, sub := range subscriptions {
-		subStart, _ := parsePeriod(sub.StartDate)
-		if sub.EndDate == nil {
-			if !subStart.Before(startPeriod) && !subStart.After(endPeriod) {
-				totalCost += sub.Price
+	var warnings []models.BudgetWarning
+	for _, budget := range userBudgets {
+		if !budgetCovers(&budget, sub) {
+			continue
+		}
+
+		costsBefore, costsAfter, err := s.budgetChange(ctx, &budget, before, sub, windowStart, windowEnd)
+		if err != nil {
+			logging.FromContext(ctx, s.logger).WithError(err).WithField("budget_id", budget.ID).Warn("Failed to check budget")
+			continue
+		}
+
+		for i, cost := range costsAfter {
+			if cost <= budget.MonthlyLimit || costsBefore[i] > budget.MonthlyLimit {
+				continue
 			}
-		} else {
-			subEnd, _ := parsePeriod(*sub.EndDate)
-			overlapMonths := calculateOverlapMonths(startPeriod, endPeriod, subStart, subEnd)
-			totalCost += sub.Price * overlapMonths
+			month := formatPeriod(windowStart.AddDate(0, i, 0))
+			warnings = append(warnings, models.BudgetWarning{
+				BudgetID:     budget.ID,
+				ServiceName:  budget.ServiceName,
+				MonthlyLimit: budget.MonthlyLimit,
+				Month:        month,
+				PlannedCost:  cost,
+				Message:      fmt.Sprintf("planned spend %d exceeds the monthly budget of %d in %s", cost, budget.MonthlyLimit, month),
+			})
+			break
 		}
 	}
 
-	response := &models.TotalCostResponse{TotalCost: totalCost}
+	if len(warnings) > 0 {
+		logging.FromContext(ctx, s.logger).WithFields(logrus.Fields{
+			"id":       sub.ID,
+			"user_id":  sub.UserID,
+			"warnings": len(warnings),
+		}).Warn("Subscription change exceeds budget")
+	}
+	return warnings
+}
 
-	s.logger.WithFields(logrus.Fields{
-		"start_period": req.StartPeriod,
-		"end_period":   req.EndPeriod,
-		"user_id":      req.UserID,
-		"service_name": req.ServiceName,
-		"total_cost":   totalCost,
-	}).Info("Total cost calculated")
+// budgetChange returns the planned spend of the budget in every month of the
+// window before and after sub was stored in place of before.
+func (s *subscriptionService) budgetChange(ctx context.Context, budget *models.Budget, before, sub *models.Subscription,
+	windowStart, windowEnd time.Time) ([]int, []int, error) {
+	subscriptions, converter, err := loadPlanned(ctx, s.repo, s.rates, s.opts.BaseCurrency, budgetFilter(budget), windowStart, windowEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	costsAfter, err := sumPlanned(subscriptions, converter, windowStart, windowEnd)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return response, nil
+	previous := make([]models.Subscription, 0, len(subscriptions))
+	for _, other := range subscriptions {
+		if other.ID != sub.ID {
+			previous = append(previous, other)
+		}
+	}
+	if before != nil && budgetCovers(budget, before) {
+		previous = append(previous, *before)
+	}
+	costsBefore, err := sumPlanned(previous, converter, windowStart, windowEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return costsBefore, costsAfter, nil
+}
+
+func costFilter(userID *uuid.UUID, serviceName *string) models.SubscriptionFilter {
+	filter := models.SubscriptionFilter{ServiceName: serviceName}
+	if userID != nil {
+		filter.UserIDs = []uuid.UUID{*userID}
+	}
+	return filter
+}
+
+// validateSubscriptionFilter checks the dates and price range of a filter.
+func validateSubscriptionFilter(filter *models.SubscriptionFilter) error {
+	dates := []struct {
+		name  string
+		value *string
+	}{
+		{"active_at", filter.ActiveAt},
+		{"start_from", filter.StartFrom},
+		{"start_to", filter.StartTo},
+		{"end_from", filter.EndFrom},
+		{"end_to", filter.EndTo},
+	}
+	for _, date := range dates {
+		if date.value != nil && !isValidDateFormat(*date.value) {
+			return invalidField(date.name, "must be in MM-YYYY format")
+		}
+	}
+
+	if filter.PriceMin != nil && *filter.PriceMin < 0 {
+		return invalidField("price_min", "must not be negative")
+	}
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		return invalidField("price_min", "must be less than or equal to price_max")
+	}
+	return nil
 }
 
 func isValidDateFormat(date string) bool {
@@ -228,17 +685,82 @@ func parsePeriod(period string) (time.Time, error) {
 	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), nil
 }
 
-func calculateOverlapMonths(periodStart, periodEnd, subStart, subEnd time.Time) int {
-	overlapStart := maxTime(periodStart, subStart)
-	overlapEnd := minTime(periodEnd, subEnd)
+func isValidBillingPeriod(period string) bool {
+	switch period {
+	case models.BillingPeriodWeek, models.BillingPeriodMonth, models.BillingPeriodQuarter, models.BillingPeriodYear:
+		return true
+	}
+	return false
+}
+
+// currentPeriod returns the first day of the current month.
+func currentPeriod() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func formatPeriod(t time.Time) string {
+	return fmt.Sprintf("%02d-%04d", int(t.Month()), t.Year())
+}
+
+// setPrice adds or replaces the price effective from the given month, keeping
+// the history ordered.
+func setPrice(prices []models.SubscriptionPrice, effectiveFrom string, price int) []models.SubscriptionPrice {
+	from, _ := parsePeriod(effectiveFrom)
+	effectiveFrom = formatPeriod(from)
+	result := make([]models.SubscriptionPrice, 0, len(prices)+1)
+	inserted := false
+	for _, p := range prices {
+		existingFrom, _ := parsePeriod(p.EffectiveFrom)
+		if !inserted && !existingFrom.Before(from) {
+			result = append(result, models.SubscriptionPrice{EffectiveFrom: effectiveFrom, Price: price})
+			inserted = true
+			if existingFrom.Equal(from) {
+				continue
+			}
+		}
+		result = append(result, p)
+	}
+	if !inserted {
+		result = append(result, models.SubscriptionPrice{EffectiveFrom: effectiveFrom, Price: price})
+	}
+	return result
+}
 
-	if overlapStart.After(overlapEnd) {
-		return 0
+// subscriptionOverlap returns the months both subscriptions are active in. A
+// nil end means both are open-ended.
+func subscriptionOverlap(a, b models.Subscription) (models.SubscriptionOverlap, bool) {
+	aStart, _ := parsePeriod(a.StartDate)
+	bStart, _ := parsePeriod(b.StartDate)
+	overlap := models.SubscriptionOverlap{
+		FirstID:  a.ID,
+		SecondID: b.ID,
+		Start:    formatPeriod(maxTime(aStart, bStart)),
+	}
+
+	var end *time.Time
+	for _, sub := range []models.Subscription{a, b} {
+		if sub.EndDate == nil || *sub.EndDate == "" {
+			continue
+		}
+		subEnd, _ := parsePeriod(*sub.EndDate)
+		if end == nil || subEnd.Before(*end) {
+			end = &subEnd
+		}
 	}
+	if end != nil {
+		if end.Before(maxTime(aStart, bStart)) {
+			return overlap, false
+		}
+		endPeriod := formatPeriod(*end)
+		overlap.End = &endPeriod
+	}
+	return overlap, true
+}
 
-	yearDiff := overlapEnd.Year() - overlapStart.Year()
-	monthDiff := int(overlapEnd.Month()) - int(overlapStart.Month())
-	return yearDiff*12 + monthDiff + 1
+// monthDiff returns the number of whole months from a to b.
+func monthDiff(a, b time.Time) int {
+	return (b.Year()-a.Year())*12 + int(b.Month()) - int(a.Month())
 }
 
 func maxTime(a, b time.Time) time.Time {
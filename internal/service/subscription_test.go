@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+
+	"em_subscription_test/internal/repository"
+	"em_subscription_test/models"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeSubscriptions is an in-memory SubscriptionRepository. Transactions work
+// on a copy that is kept only if fn succeeds. Methods the tests do not use
+// panic through the nil embedded interface.
+type fakeSubscriptions struct {
+	repository.SubscriptionRepository
+	rows map[uuid.UUID]models.Subscription
+}
+
+func newFakeSubscriptions() *fakeSubscriptions {
+	return &fakeSubscriptions{rows: make(map[uuid.UUID]models.Subscription)}
+}
+
+func (f *fakeSubscriptions) Create(_ context.Context, sub *models.Subscription) error {
+	f.rows[sub.ID] = *sub
+	return nil
+}
+
+func (f *fakeSubscriptions) GetByID(_ context.Context, id uuid.UUID) (*models.Subscription, error) {
+	sub, ok := f.rows[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &sub, nil
+}
+
+func (f *fakeSubscriptions) Update(_ context.Context, sub *models.Subscription) error {
+	stored, ok := f.rows[sub.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if stored.Version != sub.Version {
+		return repository.ErrVersionConflict
+	}
+	sub.Version++
+	f.rows[sub.ID] = *sub
+	return nil
+}
+
+func (f *fakeSubscriptions) Delete(_ context.Context, id uuid.UUID, version *int) error {
+	stored, ok := f.rows[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if version != nil && *version != stored.Version {
+		return repository.ErrVersionConflict
+	}
+	delete(f.rows, id)
+	return nil
+}
+
+func (f *fakeSubscriptions) Transaction(ctx context.Context, fn func(repo repository.SubscriptionRepository) error) error {
+	tx := &fakeSubscriptions{rows: make(map[uuid.UUID]models.Subscription, len(f.rows))}
+	for id, sub := range f.rows {
+		tx.rows[id] = sub
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	f.rows = tx.rows
+	return nil
+}
+
+// fakeBudgets is an in-memory BudgetRepository.
+type fakeBudgets struct {
+	repository.BudgetRepository
+	rows map[uuid.UUID]models.Budget
+}
+
+func newFakeBudgets() *fakeBudgets {
+	return &fakeBudgets{rows: make(map[uuid.UUID]models.Budget)}
+}
+
+func (f *fakeBudgets) Create(_ context.Context, budget *models.Budget) error {
+	f.rows[budget.ID] = *budget
+	return nil
+}
+
+func (f *fakeBudgets) GetByID(_ context.Context, id uuid.UUID) (*models.Budget, error) {
+	budget, ok := f.rows[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &budget, nil
+}
+
+func (f *fakeBudgets) Update(_ context.Context, budget *models.Budget) error {
+	if _, ok := f.rows[budget.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	f.rows[budget.ID] = *budget
+	return nil
+}
+
+func (f *fakeBudgets) Delete(_ context.Context, id uuid.UUID) error {
+	if _, ok := f.rows[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(f.rows, id)
+	return nil
+}
+
+func (f *fakeBudgets) List(_ context.Context, filter models.BudgetFilter) ([]models.Budget, error) {
+	var budgets []models.Budget
+	for _, budget := range f.rows {
+		if filter.UserID == nil || budget.UserID == *filter.UserID {
+			budgets = append(budgets, budget)
+		}
+	}
+	return budgets, nil
+}
+
+func newTestService(repo *fakeSubscriptions) *subscriptionService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &subscriptionService{repo: repo, budgets: newFakeBudgets(), logger: logger, opts: SubscriptionOptions{BaseCurrency: "RUB"}}
+}
+
+func intPtr(n int) *int { return &n }
+
+func validCreate() models.SubscriptionCreate {
+	return models.SubscriptionCreate{
+		ServiceName: "Yandex Plus",
+		Price:       intPtr(400),
+		UserID:      uuid.New(),
+		StartDate:   "07-2025",
+	}
+}
+
+// assertInvalidField fails unless err is a ValidationError of field.
+func assertInvalidField(t *testing.T, err error, field string) {
+	t.Helper()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Fields) == 0 || invalid.Fields[0].Field != field {
+		t.Fatalf("err = %v, want a ValidationError of %s", err, field)
+	}
+}
+
+func TestCreateAcceptsZeroPrice(t *testing.T) {
+	svc := newTestService(newFakeSubscriptions())
+	req := validCreate()
+	req.Price = intPtr(0)
+
+	sub, _, err := svc.Create(context.Background(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Price != 0 {
+		t.Errorf("price = %d, want 0", sub.Price)
+	}
+}
+
+func TestCreateRequiresPrice(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+	req := validCreate()
+	req.Price = nil
+
+	_, _, err := svc.Create(context.Background(), &req)
+	assertInvalidField(t, err, "price")
+	if len(repo.rows) != 0 {
+		t.Errorf("stored %d subscriptions, want none", len(repo.rows))
+	}
+}
+
+func TestReplaceRequiresPrice(t *testing.T) {
+	repo := newFakeSubscriptions()
+	svc := newTestService(repo)
+	req := validCreate()
+	sub, _, err := svc.Create(context.Background(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replace := models.SubscriptionReplace{SubscriptionCreate: validCreate()}
+	replace.Price = nil
+	_, _, err = svc.Replace(context.Background(), sub.ID, &replace, nil)
+	assertInvalidField(t, err, "price")
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"em_subscription_test/internal/logging"
+	"em_subscription_test/internal/service"
+	"em_subscription_test/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Purger periodically removes subscriptions that have been in the trash for
+// longer than the retention period.
+type Purger struct {
+	service   service.SubscriptionService
+	retention time.Duration
+	interval  time.Duration
+	logger    *logrus.Logger
+
+	mu     sync.Mutex
+	status models.WorkerStatus
+}
+
+func NewPurger(svc service.SubscriptionService, retention, interval time.Duration, logger *logrus.Logger) *Purger {
+	return &Purger{service: svc, retention: retention, interval: interval, logger: logger}
+}
+
+// Run purges the trash right away and then every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.setRunning(true)
+	defer p.setRunning(false)
+
+	logger := p.logger.WithField("worker", "purge")
+	ctx = logging.NewContext(ctx, logger)
+
+	logger.WithFields(logrus.Fields{
+		"retention": p.retention,
+		"interval":  p.interval,
+	}).Info("Trash purger started")
+	for {
+		// Failures are logged by the service, the next run retries.
+		_, err := p.service.PurgeDeleted(ctx, p.retention)
+		p.finishRun(err)
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Trash purger stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status reports whether the purger is running and how its last run went.
+func (p *Purger) Status() models.WorkerStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+func (p *Purger) setRunning(running bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Running = running
+}
+
+func (p *Purger) finishRun(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.status.LastRun = &now
+	p.status.LastError = ""
+	if err != nil {
+		p.status.LastError = err.Error()
+	}
+}
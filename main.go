@@ -1,14 +1,19 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"em_subscription_test/config"
 	"em_subscription_test/internal/app"
+	"em_subscription_test/internal/logging"
 
 	_ "em_subscription_test/docs"
-
-	"github.com/sirupsen/logrus"
 )
 
 // @title Subscription API
@@ -18,23 +23,68 @@ import (
 // @BasePath /api/v1/
 
 func main() {
-	g, err := app.InitializeApp()
-	if err != nil {
-		log.Fatal("Failed to initialize application:", err)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		runConfigCommand(os.Args[2:])
+		return
 	}
 
-	cfg := config.Load()
+	cfg := loadConfig(os.Args[0], os.Args[1:])
+	logger := logging.New(cfg.LogLevel, cfg.LogFormat)
 
-	logger := logrus.New()
-	level, err := logrus.ParseLevel(cfg.LogLevel)
+	application, err := app.InitializeApp(cfg, logger)
 	if err != nil {
-		log.Printf("Invalid log level %s, using info", cfg.LogLevel)
-		level = logrus.InfoLevel
+		logger.WithError(err).Fatal("Failed to initialize application")
 	}
-	logger.SetLevel(level)
 
-	logger.WithField("port", cfg.ServerPort).Info("Starting server")
-	if err := g.Run(":" + cfg.ServerPort); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- application.Run()
+	}()
+	select {
+	case err := <-errs:
 		logger.WithError(err).Fatal("Failed to start server")
+	case <-ctx.Done():
+	}
+
+	logger.WithField("timeout", cfg.ShutdownTimeout).Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := application.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Failed to shut down cleanly")
+		return
+	}
+	logger.Info("Server stopped")
+}
+
+// runConfigCommand runs "config print", which writes the effective
+// configuration with secrets redacted.
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintf(os.Stderr, "usage: %s config print [flags]\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	cfg := loadConfig(os.Args[0]+" config print", args[1:])
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the configuration, or exits listing its problems.
+func loadConfig(name string, args []string) *config.Config {
+	cfg, err := config.Load(name, args, os.Stderr)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, config.ErrUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	return cfg
 }
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period VARCHAR(10) NOT NULL DEFAULT 'month'
+        CHECK (billing_period IN ('week', 'month', 'quarter', 'year')),
+    ADD COLUMN IF NOT EXISTS billing_anchor VARCHAR(7);
+
+-- +goose Down
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS billing_anchor,
+    DROP COLUMN IF EXISTS billing_period;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency VARCHAR(3) NOT NULL,
+    month VARCHAR(7) NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (currency, month)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS currency;
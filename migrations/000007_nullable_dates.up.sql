@@ -0,0 +1,12 @@
+-- +goose Up
+UPDATE subscriptions SET end_date = NULL WHERE end_date = '';
+UPDATE subscriptions SET billing_anchor = NULL WHERE billing_anchor = '';
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_end_date_not_empty CHECK (end_date <> ''),
+    ADD CONSTRAINT subscriptions_billing_anchor_not_empty CHECK (billing_anchor <> '');
+
+-- +goose Down
+ALTER TABLE subscriptions
+    DROP CONSTRAINT IF EXISTS subscriptions_billing_anchor_not_empty,
+    DROP CONSTRAINT IF EXISTS subscriptions_end_date_not_empty;
@@ -0,0 +1,8 @@
+// Package migrations embeds the goose migrations, so the binary applies and
+// checks them without depending on its working directory.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,58 @@
+package models
+
+import "github.com/google/uuid"
+
+// Batch modes: atomic applies every item of a batch in one transaction or
+// none of them, best_effort applies each item on its own.
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+type SubscriptionBatchCreate struct {
+	Mode  string               `json:"mode,omitempty" enums:"atomic,best_effort"` // defaults to atomic
+	Items []SubscriptionCreate `json:"items"`
+}
+
+type SubscriptionBatchUpdate struct {
+	Mode  string                   `json:"mode,omitempty" enums:"atomic,best_effort"` // defaults to atomic
+	Items []SubscriptionBatchPatch `json:"items"`
+}
+
+// SubscriptionBatchPatch is a JSON Merge Patch of one subscription of a
+// batch.
+type SubscriptionBatchPatch struct {
+	ID      uuid.UUID          `json:"id"`
+	IfMatch *int               `json:"if_match,omitempty"` // version the change is based on, like the If-Match header
+	Patch   SubscriptionUpdate `json:"patch"`
+}
+
+type SubscriptionBatchDelete struct {
+	Mode  string                        `json:"mode,omitempty" enums:"atomic,best_effort"` // defaults to atomic
+	Items []SubscriptionBatchDeleteItem `json:"items"`
+}
+
+type SubscriptionBatchDeleteItem struct {
+	ID      uuid.UUID `json:"id"`
+	IfMatch *int      `json:"if_match,omitempty"` // version the deletion is based on, like the If-Match header
+}
+
+// SubscriptionBatchResponse reports the outcome of every item of a batch in
+// request order.
+type SubscriptionBatchResponse struct {
+	Mode      string                    `json:"mode"`
+	Succeeded int                       `json:"succeeded"`
+	Failed    int                       `json:"failed"`
+	Items     []SubscriptionBatchResult `json:"items"`
+}
+
+type SubscriptionBatchResult struct {
+	Index         int             `json:"index"`
+	Status        int             `json:"status"` // HTTP status the item would get as a single request
+	Subscription  *Subscription   `json:"subscription,omitempty"`
+	Warnings      []BudgetWarning `json:"warnings,omitempty"`
+	Code          string          `json:"code,omitempty"` // error code, as in problem responses
+	Error         string          `json:"error,omitempty"`
+	Errors        []FieldError    `json:"errors,omitempty"` // invalid fields of the item
+	ConflictingID *uuid.UUID      `json:"conflicting_id,omitempty"`
+}
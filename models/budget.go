@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Budget struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	ServiceName  *string   `json:"service_name,omitempty" db:"service_name"` // nil applies the limit to all services of the user
+	MonthlyLimit int       `json:"monthly_limit" db:"monthly_limit"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BudgetFilter narrows down budgets. Nil fields match every budget, the rest
+// must all match.
+type BudgetFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string // exact match
+}
+
+type BudgetCreate struct {
+	UserID       uuid.UUID `json:"user_id" binding:"required"`
+	ServiceName  *string   `json:"service_name,omitempty"`
+	MonthlyLimit *int      `json:"monthly_limit" binding:"required,min=0"` // 0 warns about any spending
+}
+
+type BudgetUpdate struct {
+	UserID       *uuid.UUID `json:"user_id,omitempty"`
+	ServiceName  *string    `json:"service_name,omitempty"` // empty string removes the service scope
+	MonthlyLimit *int       `json:"monthly_limit,omitempty"`
+}
+
+type BudgetStatus struct {
+	Budget      Budget              `json:"budget"`
+	StartPeriod string              `json:"start_period"` // MM-YYYY
+	EndPeriod   string              `json:"end_period"`   // MM-YYYY
+	Months      []BudgetMonthStatus `json:"months"`
+}
+
+type BudgetMonthStatus struct {
+	Month       string `json:"month"` // MM-YYYY
+	Limit       int    `json:"limit"`
+	PlannedCost int    `json:"planned_cost"`
+	Remaining   int    `json:"remaining"`
+	OverBudget  bool   `json:"over_budget"`
+}
+
+// BudgetWarning reports a budget exceeded after a subscription change. It
+// never blocks the change itself.
+type BudgetWarning struct {
+	BudgetID     uuid.UUID `json:"budget_id"`
+	ServiceName  *string   `json:"service_name,omitempty"`
+	MonthlyLimit int       `json:"monthly_limit"`
+	Month        string    `json:"month"` // first month over budget, MM-YYYY
+	PlannedCost  int       `json:"planned_cost"`
+	Message      string    `json:"message"`
+}
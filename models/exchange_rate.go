@@ -0,0 +1,19 @@
+package models
+
+// ExchangeRate is the value of one unit of Currency in the base currency
+// during Month.
+type ExchangeRate struct {
+	Currency string  `json:"currency" db:"currency"`
+	Month    string  `json:"month" db:"month"` // MM-YYYY
+	Rate     float64 `json:"rate" db:"rate"`
+}
+
+type ExchangeRateImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// MissingRate names a month without an exchange rate for a currency.
+type MissingRate struct {
+	Currency string `json:"currency"`
+	Month    string `json:"month"` // MM-YYYY
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+// HealthResponse is the result of a liveness or readiness probe. Liveness
+// has no checks.
+type HealthResponse struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the outcome of one readiness check. Details depend on
+// the check, such as a MigrationStatus or a WorkerStatus.
+type HealthCheckResult struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// MigrationStatus compares the schema version of the database with the
+// newest migration embedded in the binary.
+type MigrationStatus struct {
+	Current  int64 `json:"current"`
+	Expected int64 `json:"expected"`
+}
+
+// WorkerStatus is the state of a background worker.
+type WorkerStatus struct {
+	Running   bool       `json:"running"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the stored response of a request sent with an
+// Idempotency-Key. StatusCode is nil while the first request is in progress.
+type IdempotencyRecord struct {
+	Scope           string    `db:"scope"` // method and route
+	Key             string    `db:"key"`
+	RequestHash     string    `db:"request_hash"` // hex SHA-256 of the request body
+	StatusCode      *int      `db:"status_code"`
+	ResponseHeaders []byte    `db:"response_headers"` // JSON object
+	ResponseBody    []byte    `db:"response_body"`
+	CreatedAt       time.Time `db:"created_at"`
+	ExpiresAt       time.Time `db:"expires_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// Subscription import formats.
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// SubscriptionImportResponse counts the rows of an import and reports the
+// invalid ones. Nothing is written on a dry run.
+type SubscriptionImportResponse struct {
+	DryRun          bool              `json:"dry_run"`
+	Total           int               `json:"total"` // data rows read
+	Valid           int               `json:"valid"`
+	Imported        int               `json:"imported"` // always 0 on a dry run
+	Failed          int               `json:"failed"`
+	Errors          []ImportLineError `json:"errors"`
+	ErrorsTruncated bool              `json:"errors_truncated,omitempty"` // more rows failed than errors lists
+}
+
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "encoding/json"
+
+// Optional is a field of a JSON Merge Patch (RFC 7396) document. It tells an
+// absent member (Set is false) apart from an explicit null (Set and Null are
+// true), which clears the field.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Ptr returns nil if the field is absent or null, else a pointer to its
+// value.
+func (o Optional[T]) Ptr() *T {
+	if !o.Set || o.Null {
+		return nil
+	}
+	return &o.Value
+}
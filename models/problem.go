@@ -0,0 +1,60 @@
+package models
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Error codes name the kind of a problem. Clients may rely on them, so they
+// must not change once released.
+const (
+	ErrorCodeValidation          = "validation_failed"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeDuplicate           = "duplicate_subscription"
+	ErrorCodeConcurrentUpdate    = "concurrent_update"
+	ErrorCodePreconditionFailed  = "precondition_failed"
+	ErrorCodeMissingRates        = "missing_rates"
+	ErrorCodeBatchRolledBack     = "batch_rolled_back"
+	ErrorCodeIdempotencyMismatch = "idempotency_key_reused"
+	ErrorCodeIdempotencyBusy     = "idempotency_key_in_progress"
+	ErrorCodeTimeout             = "timeout"
+	ErrorCodeCanceled            = "request_canceled"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// ProblemContentType is the media type of Problem responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details response. Code tells problems of the
+// same status apart, the other extension members are set for some codes only.
+type Problem struct {
+	Type          string        `json:"type"`
+	Title         string        `json:"title"`
+	Status        int           `json:"status"`
+	Detail        string        `json:"detail,omitempty"`
+	Instance      string        `json:"instance,omitempty"`
+	Code          string        `json:"code"`
+	Errors        []FieldError  `json:"errors,omitempty"`         // validation_failed
+	ConflictingID *uuid.UUID    `json:"conflicting_id,omitempty"` // duplicate_subscription
+	MissingRates  []MissingRate `json:"missing_rates,omitempty"`  // missing_rates
+}
+
+// FieldError is one invalid field of a request. Field is empty for problems
+// with the request as a whole.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// NewProblem returns a problem of the given status and code. The type is
+// about:blank, so the title is the status text.
+func NewProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
@@ -6,40 +6,207 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	BillingPeriodWeek    = "week"
+	BillingPeriodMonth   = "month"
+	BillingPeriodQuarter = "quarter"
+	BillingPeriodYear    = "year"
+)
+
+// Cost modes: cash counts a charge in the month it happens, amortized spreads
+// the price evenly over the months of its billing period.
+const (
+	CostModeCash      = "cash"
+	CostModeAmortized = "amortized"
+)
+
 type Subscription struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	ServiceName string    `json:"service_name" db:"service_name"`
-	Price       int       `json:"price" db:"price"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"`
-	StartDate   string    `json:"start_date" db:"start_date"`
-	EndDate     *string   `json:"end_date,omitempty" db:"end_date"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID            uuid.UUID  `json:"id" db:"id"`
+	ServiceName   string     `json:"service_name" db:"service_name"`
+	Price         int        `json:"price" db:"price"` // latest price per billing period, see prices for the history
+	Currency      string     `json:"currency" db:"currency"`
+	UserID        uuid.UUID  `json:"user_id" db:"user_id"`
+	StartDate     string     `json:"start_date" db:"start_date"`                   // MM-YYYY
+	EndDate       *string    `json:"end_date,omitempty" db:"end_date"`             // MM-YYYY or nil
+	BillingPeriod string     `json:"billing_period" db:"billing_period"`           // week, month, quarter or year
+	BillingAnchor *string    `json:"billing_anchor,omitempty" db:"billing_anchor"` // MM-YYYY of the first charge, nil means start_date
+	Version       int        `json:"version" db:"version"`                         // incremented on every update, sent as ETag
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // set while the subscription is in the trash
+
+	Prices []SubscriptionPrice `json:"prices,omitempty" db:"-"`
+}
+
+// SubscriptionPrice is the price charged from EffectiveFrom until the next
+// price takes effect.
+type SubscriptionPrice struct {
+	SubscriptionID uuid.UUID `json:"-" db:"subscription_id"`
+	EffectiveFrom  string    `json:"effective_from" db:"effective_from"` // MM-YYYY
+	Price          int       `json:"price" db:"price"`
+}
+
+// SubscriptionWithWarnings is returned by create and update, the warnings
+// never prevent the change.
+type SubscriptionWithWarnings struct {
+	Subscription
+	Warnings []BudgetWarning `json:"warnings,omitempty"`
 }
 
 type SubscriptionCreate struct {
-	ServiceName string    `json:"service_name" binding:"required"`
-	Price       int       `json:"price" binding:"required,min=0"`
-	UserID      uuid.UUID `json:"user_id" binding:"required"`
-	StartDate   string    `json:"start_date" binding:"required"`
-	EndDate     *string   `json:"end_date,omitempty"`
+	ServiceName   string    `json:"service_name" binding:"required"`
+	Price         *int      `json:"price" binding:"required,min=0"` // 0 for a free subscription
+	Currency      string    `json:"currency,omitempty"`             // ISO 4217 code, defaults to the base currency
+	UserID        uuid.UUID `json:"user_id" binding:"required"`
+	StartDate     string    `json:"start_date" binding:"required"` // MM-YYYY
+	EndDate       *string   `json:"end_date,omitempty"`
+	BillingPeriod string    `json:"billing_period,omitempty" binding:"omitempty,oneof=week month quarter year"` // defaults to month
+	BillingAnchor *string   `json:"billing_anchor,omitempty"`                                                   // MM-YYYY of the first charge, defaults to start_date
+}
+
+// SubscriptionReplace is the full representation of a subscription sent with
+// PUT. Omitted optional fields are reset to their defaults.
+type SubscriptionReplace struct {
+	SubscriptionCreate
+	PriceEffectiveFrom *string `json:"price_effective_from,omitempty"` // MM-YYYY, charge price from this month on, the current month by default
 }
 
+// SubscriptionUpdate is a JSON Merge Patch (RFC 7396) of a subscription:
+// absent members are left unchanged and null clears end_date and
+// billing_anchor.
 type SubscriptionUpdate struct {
-	ServiceName *string   `json:"service_name,omitempty"`
-	Price       *int      `json:"price,omitempty"`
-	UserID      *uuid.UUID `json:"user_id,omitempty"`
-	StartDate   *string   `json:"start_date,omitempty"`
-	EndDate     *string   `json:"end_date,omitempty"`
+	ServiceName        Optional[string]    `json:"service_name" swaggertype:"string"`
+	Price              Optional[int]       `json:"price" swaggertype:"integer"`
+	Currency           Optional[string]    `json:"currency" swaggertype:"string"`
+	UserID             Optional[uuid.UUID] `json:"user_id" swaggertype:"string" format:"uuid"`
+	StartDate          Optional[string]    `json:"start_date" swaggertype:"string"`                       // MM-YYYY
+	EndDate            Optional[string]    `json:"end_date" swaggertype:"string" extensions:"x-nullable"` // MM-YYYY, null makes the subscription open-ended
+	BillingPeriod      Optional[string]    `json:"billing_period" swaggertype:"string" enums:"week,month,quarter,year"`
+	BillingAnchor      Optional[string]    `json:"billing_anchor" swaggertype:"string" extensions:"x-nullable"` // MM-YYYY, null resets it to start_date
+	PriceEffectiveFrom *string             `json:"price_effective_from,omitempty"`                              // MM-YYYY, charge price from this month on, the current month by default
+}
+
+// SubscriptionFilter narrows down subscriptions. Nil and empty fields match
+// every subscription, the rest must all match.
+type SubscriptionFilter struct {
+	UserIDs             []uuid.UUID // any of these users
+	ServiceName         *string     // exact match
+	ServiceNamePrefix   *string     // case-insensitive
+	ServiceNameContains *string     // case-insensitive
+	ActiveAt            *string     // MM-YYYY, active in this month
+	StartFrom           *string     // MM-YYYY, inclusive
+	StartTo             *string     // MM-YYYY, inclusive
+	EndFrom             *string     // MM-YYYY, inclusive; excludes open-ended subscriptions
+	EndTo               *string     // MM-YYYY, inclusive; excludes open-ended subscriptions
+	PriceMin            *int        // current price, inclusive
+	PriceMax            *int        // current price, inclusive
+	OpenEnded           bool        // only subscriptions without end_date
+}
+
+// SubscriptionPageRequest selects a page of subscriptions.
+type SubscriptionPageRequest struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"` // defaults to 50
+	Cursor string `form:"cursor"`                                  // next_cursor of the previous page
+	Sort   string `form:"sort"`                                    // price, start_date, service_name or created_at, prefixed with - for descending; defaults to created_at
+}
+
+type SubscriptionPage struct {
+	Items      []Subscription `json:"items"`
+	NextCursor *string        `json:"next_cursor,omitempty"` // nil on the last page
 }
 
 type TotalCostRequest struct {
+	UserID         *uuid.UUID `json:"user_id,omitempty"`
+	ServiceName    *string    `json:"service_name,omitempty"`
+	StartPeriod    string     `json:"start_period" binding:"required"`                                              // MM-YYYY
+	EndPeriod      string     `json:"end_period" binding:"required"`                                                // MM-YYYY
+	AsOf           *string    `json:"as_of,omitempty"`                                                              // MM-YYYY, open-ended subscriptions are charged up to this month; defaults to end_period
+	GroupBy        []string   `json:"group_by,omitempty" binding:"omitempty,dive,oneof=service_name user_id month"` // nesting order of the breakdown
+	Mode           string     `json:"mode,omitempty" binding:"omitempty,oneof=cash amortized"`                      // defaults to cash
+	TargetCurrency string     `json:"target_currency,omitempty"`                                                    // currency of the result, defaults to the base currency
+}
+
+type TotalCostResponse struct {
+	TotalCost int            `json:"total_cost"`
+	Currency  string         `json:"currency"`
+	Breakdown []CostGroup    `json:"breakdown,omitempty"`
+	Rates     []ExchangeRate `json:"rates"` // exchange rates used for the conversion
+}
+
+type CostGroup struct {
+	Dimension string      `json:"dimension"` // service_name, user_id or month
+	Key       string      `json:"key"`
+	Cost      int         `json:"cost"`
+	Groups    []CostGroup `json:"groups,omitempty"`
+}
+
+type MonthlyCost struct {
+	Month           string      `json:"month"` // MM-YYYY
+	Cost            int         `json:"cost"`
+	SubscriptionIDs []uuid.UUID `json:"subscription_ids"`
+}
+
+type MonthlyCostResponse struct {
+	TotalCost int            `json:"total_cost"`
+	Currency  string         `json:"currency"`
+	Months    []MonthlyCost  `json:"months"`
+	Rates     []ExchangeRate `json:"rates"` // exchange rates used for the conversion
+}
+
+type ForecastRequest struct {
 	UserID      *uuid.UUID `json:"user_id,omitempty"`
 	ServiceName *string    `json:"service_name,omitempty"`
-	StartPeriod string     `json:"start_period" binding:"required"`
-	EndPeriod   string     `json:"end_period" binding:"required"`
+	Months      int        `json:"months" binding:"required,min=1,max=120"`
+	From        *string    `json:"from,omitempty"`                                          // MM-YYYY, first forecast month; defaults to next month
+	Mode        string     `json:"mode,omitempty" binding:"omitempty,oneof=cash amortized"` // defaults to cash
 }
 
-type TotalCostResponse struct {
-	TotalCost int `json:"total_cost"`
+type ForecastResponse struct {
+	StartPeriod   string                 `json:"start_period"` // MM-YYYY
+	EndPeriod     string                 `json:"end_period"`   // MM-YYYY
+	TotalCost     int                    `json:"total_cost"`
+	Currency      string                 `json:"currency"`
+	Months        []ForecastMonth        `json:"months"`
+	Services      []ServiceCost          `json:"services"`
+	Subscriptions []ForecastSubscription `json:"subscriptions"`
+}
+
+type ForecastMonth struct {
+	Month    string        `json:"month"` // MM-YYYY
+	Cost     int           `json:"cost"`
+	Services []ServiceCost `json:"services"`
+}
+
+type ServiceCost struct {
+	ServiceName string `json:"service_name"`
+	Cost        int    `json:"cost"`
+}
+
+type ForecastSubscription struct {
+	ID            uuid.UUID `json:"id"`
+	ServiceName   string    `json:"service_name"`
+	UserID        uuid.UUID `json:"user_id"`
+	Price         int       `json:"price"`
+	Currency      string    `json:"currency"`
+	BillingPeriod string    `json:"billing_period"`
+	StartDate     string    `json:"start_date"`         // MM-YYYY
+	EndDate       *string   `json:"end_date,omitempty"` // MM-YYYY or nil
+	Cost          int       `json:"cost"`               // cost within the forecast window
+	EndsInWindow  bool      `json:"ends_in_window"`
+}
+
+// DuplicateGroup holds subscriptions of one user and service that overlap
+// each other.
+type DuplicateGroup struct {
+	UserID        uuid.UUID             `json:"user_id"`
+	ServiceName   string                `json:"service_name"`
+	Subscriptions []Subscription        `json:"subscriptions"`
+	Overlaps      []SubscriptionOverlap `json:"overlaps"`
+}
+
+type SubscriptionOverlap struct {
+	FirstID  uuid.UUID `json:"first_id"`
+	SecondID uuid.UUID `json:"second_id"`
+	Start    string    `json:"start"`         // MM-YYYY
+	End      *string   `json:"end,omitempty"` // MM-YYYY, nil if both are open-ended
 }